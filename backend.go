@@ -0,0 +1,182 @@
+package regobrick
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/quagmt/udecimal"
+	"github.com/sky1core/regobrick/internal/decimal"
+)
+
+// defaultBigPrecision is the precision of a BigDecimalBackend that sets none.
+const defaultBigPrecision = 38
+
+// DecimalBackend selects the number implementation of decimal arithmetic; see
+// WithBackend. It is implemented by UDecimalBackend and BigDecimalBackend.
+type DecimalBackend interface {
+	configure(cfg *decimalArithmeticConfig)
+}
+
+// UDecimalBackend computes with udecimal, the default: fixed-point numbers with
+// up to 19 fractional digits, on a zero-allocation fast path for coefficients up
+// to 128 bits. Numbers with more fractional digits (e.g. 1e-25) fail to parse,
+// and inexact results are truncated (or rounded, see WithRoundingMode) to 19
+// fractional digits.
+type UDecimalBackend struct{}
+
+func (UDecimalBackend) configure(cfg *decimalArithmeticConfig) {
+	cfg.big = false
+	cfg.precision = 0
+}
+
+// BigDecimalBackend computes exactly with math/big rationals. Numbers parse with
+// any number of fractional digits, e.g. token amounts with 24 decimals or 1e-25;
+// exponents are limited to ±1000 to bound allocations. +, -, %, abs and sum are
+// exact; the results of /, * and product() are truncated (or rounded, see
+// WithRoundingMode) to Precision fractional digits.
+//
+// It allocates on every operation and is several times slower than
+// UDecimalBackend.
+type BigDecimalBackend struct {
+	// Precision is the number of fractional digits of inexact results, and the
+	// largest WithDivisionScale and decimal.div scale. Zero selects 38.
+	Precision int
+}
+
+func (b BigDecimalBackend) configure(cfg *decimalArithmeticConfig) {
+	cfg.big = true
+	cfg.precision = b.Precision
+	if cfg.precision == 0 {
+		cfg.precision = defaultBigPrecision
+	}
+}
+
+// WithBackend selects the number implementation of the decimal operators and
+// aggregates: UDecimalBackend (the default) or BigDecimalBackend, e.g.
+//
+//	regobrick.UseDecimalArithmetic(
+//	    regobrick.WithBackend(regobrick.BigDecimalBackend{Precision: 30}),
+//	)
+//
+// Every operator behaves the same with either backend within udecimal's limits.
+// The decimal_literals feature checks literals against UDecimalBackend unless
+// ParseOptions.BigDecimalLiterals is set. It panics if backend is nil or a BigDecimalBackend has a negative Precision.
+func WithBackend(backend DecimalBackend) DecimalArithmeticOption {
+	if backend == nil {
+		panic("regobrick: nil decimal backend")
+	}
+	if b, ok := backend.(BigDecimalBackend); ok && b.Precision < 0 {
+		panic(fmt.Sprintf("regobrick: negative big decimal precision %d", b.Precision))
+	}
+	return backend.configure
+}
+
+// number is an operand or result of decimal arithmetic: a udecimal.Decimal, or
+// an exact *big.Rat with BigDecimalBackend. All numbers of one operation come
+// from the same configuration, so they never mix representations.
+type number struct {
+	d udecimal.Decimal
+	r *big.Rat
+}
+
+// parseNumber parses a numeric string with the configured backend.
+func (cfg *decimalArithmeticConfig) parseNumber(s string) (number, error) {
+	if cfg.big {
+		r, err := decimal.ParseRat(s)
+		if err != nil {
+			return number{}, err
+		}
+		return number{r: r}, nil
+	}
+	d, err := parseDecimal(s)
+	if err != nil {
+		return number{}, err
+	}
+	return number{d: d}, nil
+}
+
+// maxScale returns the number of fractional digits the backend gives inexact
+// results.
+func (cfg *decimalArithmeticConfig) maxScale() int {
+	if cfg.big {
+		return cfg.precision
+	}
+	return decimal.MaxScale
+}
+
+// rat returns the exact value of n.
+func (n number) rat() *big.Rat {
+	if n.r != nil {
+		return n.r
+	}
+	return decimal.Rat(n.d)
+}
+
+// String formats n in plain notation.
+func (n number) String() string {
+	if n.r != nil {
+		return decimal.FormatRat(n.r)
+	}
+	return n.d.String()
+}
+
+func (n number) add(m number) number {
+	if n.r != nil {
+		return number{r: new(big.Rat).Add(n.r, m.r)}
+	}
+	return number{d: n.d.Add(m.d)}
+}
+
+func (n number) sub(m number) number {
+	if n.r != nil {
+		return number{r: new(big.Rat).Sub(n.r, m.r)}
+	}
+	return number{d: n.d.Sub(m.d)}
+}
+
+// mod returns the remainder of n / m truncated toward zero, with the sign of n.
+func (n number) mod(m number) (number, error) {
+	if n.r != nil {
+		q := new(big.Rat).Quo(n.r, m.r)
+		trunc := new(big.Int).Quo(q.Num(), q.Denom())
+		prod := new(big.Rat).Mul(new(big.Rat).SetInt(trunc), m.r)
+		return number{r: prod.Sub(n.r, prod)}, nil
+	}
+	d, err := n.d.Mod(m.d)
+	return number{d: d}, err
+}
+
+func (n number) cmp(m number) int {
+	if n.r != nil {
+		return n.r.Cmp(m.r)
+	}
+	return n.d.Cmp(m.d)
+}
+
+func (n number) isZero() bool {
+	if n.r != nil {
+		return n.r.Sign() == 0
+	}
+	return n.d.IsZero()
+}
+
+func (n number) abs() number {
+	if n.r != nil {
+		return number{r: new(big.Rat).Abs(n.r)}
+	}
+	return number{d: n.d.Abs()}
+}
+
+func (n number) ceil() number {
+	if n.r != nil {
+		return number{r: decimal.RoundRat(n.r, 0, decimal.RoundCeiling)}
+	}
+	return number{d: n.d.Ceil()}
+}
+
+func (n number) floor() number {
+	if n.r != nil {
+		return number{r: decimal.RoundRat(n.r, 0, decimal.RoundFloor)}
+	}
+	return number{d: n.d.Floor()}
+}
@@ -196,3 +196,83 @@ result := pin_struct_echo({"x": 1, "y": "a"})`
 		t.Fatalf("struct round-trip: got %#v, want %#v", got, want)
 	}
 }
+
+// Pin: the result of every operator WithRoundingMode affects, for each mode and
+// for the default (truncating division and multiplication, round() half away
+// from zero). 0.0000000003 * 0.0000000005 is 1.5e-19, a tie at the 19th decimal
+// place.
+func TestPin_RoundingModes(t *testing.T) {
+	ensureDecimalArithmeticEnabled()
+	t.Cleanup(func() {
+		UseDecimalArithmetic()
+	})
+
+	module := `package test
+import rego.v1
+result := {
+	"div": 2 / 3,
+	"div_neg": -2 / 3,
+	"mul": 0.0000000003 * 0.0000000005,
+	"mul_neg": -0.0000000003 * 0.0000000005,
+	"product": product([0.0000000003, 0.0000000005]),
+	"round": round(2.5),
+	"round_neg": round(-2.5),
+}`
+	const (
+		third    = "0.6666666666666666666"
+		thirdUp  = "0.6666666666666666667"
+		ulp      = "0.0000000000000000001"
+		ulpTwice = "0.0000000000000000002"
+	)
+	tests := []struct {
+		name string
+		opts []DecimalArithmeticOption
+		want map[string]string
+	}{
+		{"default", nil, map[string]string{
+			"div": third, "div_neg": "-" + third, "mul": ulp, "mul_neg": "-" + ulp,
+			"product": ulp, "round": "3", "round_neg": "-3",
+		}},
+		{"down", []DecimalArithmeticOption{WithRoundingMode(RoundDown)}, map[string]string{
+			"div": third, "div_neg": "-" + third, "mul": ulp, "mul_neg": "-" + ulp,
+			"product": ulp, "round": "2", "round_neg": "-2",
+		}},
+		{"up", []DecimalArithmeticOption{WithRoundingMode(RoundUp)}, map[string]string{
+			"div": thirdUp, "div_neg": "-" + thirdUp, "mul": ulpTwice, "mul_neg": "-" + ulpTwice,
+			"product": ulpTwice, "round": "3", "round_neg": "-3",
+		}},
+		{"ceiling", []DecimalArithmeticOption{WithRoundingMode(RoundCeiling)}, map[string]string{
+			"div": thirdUp, "div_neg": "-" + third, "mul": ulpTwice, "mul_neg": "-" + ulp,
+			"product": ulpTwice, "round": "3", "round_neg": "-2",
+		}},
+		{"floor", []DecimalArithmeticOption{WithRoundingMode(RoundFloor)}, map[string]string{
+			"div": third, "div_neg": "-" + thirdUp, "mul": ulp, "mul_neg": "-" + ulpTwice,
+			"product": ulp, "round": "2", "round_neg": "-3",
+		}},
+		{"half_up", []DecimalArithmeticOption{WithRoundingMode(RoundHalfUp)}, map[string]string{
+			"div": thirdUp, "div_neg": "-" + thirdUp, "mul": ulpTwice, "mul_neg": "-" + ulpTwice,
+			"product": ulpTwice, "round": "3", "round_neg": "-3",
+		}},
+		{"half_down", []DecimalArithmeticOption{WithRoundingMode(RoundHalfDown)}, map[string]string{
+			"div": thirdUp, "div_neg": "-" + thirdUp, "mul": ulp, "mul_neg": "-" + ulp,
+			"product": ulp, "round": "2", "round_neg": "-2",
+		}},
+		{"half_even", []DecimalArithmeticOption{WithRoundingMode(RoundHalfEven)}, map[string]string{
+			"div": thirdUp, "div_neg": "-" + thirdUp, "mul": ulpTwice, "mul_neg": "-" + ulpTwice,
+			"product": ulpTwice, "round": "2", "round_neg": "-2",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			UseDecimalArithmetic(tt.opts...)
+			rs := evalModuleResult(t, module, nil)
+			got := map[string]string{}
+			for k, v := range requireSingleExprValue(t, rs).(map[string]any) {
+				got[k] = v.(json.Number).String()
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package regobrick
+
+import (
+	"io"
+
+	"github.com/open-policy-agent/opa/v1/bundle"
+	"github.com/sky1core/regobrick/internal/module"
+)
+
+// BundleOptions is an alias for module.BundleOptions. It configures the imports,
+// import rules, features and Rego version applied by LoadBundle.
+type BundleOptions = module.BundleOptions
+
+// LoadBundle reads a gzipped tarball OPA bundle from r and runs every module
+// through ParseModuleWithOptions, so injected imports and features such as
+// default_false apply to bundled policies too. Data files, the manifest and its
+// roots are kept. The result can be passed to rego.ParsedBundle.
+//
+// Every module is processed even after a failure: the returned error joins one
+// *ModuleError per failing module. Bundle signatures are not verified.
+//
+// Example:
+//
+//	b, err := regobrick.LoadBundle(resp.Body, regobrick.BundleOptions{
+//	    Features: []string{"default_false"},
+//	})
+//	if err != nil {
+//	    return err
+//	}
+//	r := rego.New(rego.ParsedBundle("policies", b), rego.Query("data.app.allow"))
+func LoadBundle(r io.Reader, opts BundleOptions) (*bundle.Bundle, error) {
+	return module.LoadBundle(r, opts)
+}
+
+// LoadBundleFile behaves like LoadBundle for a bundle on disk, either a gzipped
+// tarball (e.g. bundle.tar.gz) or a directory.
+func LoadBundleFile(path string, opts BundleOptions) (*bundle.Bundle, error) {
+	return module.LoadBundleFile(path, opts)
+}
@@ -0,0 +1,57 @@
+package regobrick_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/open-policy-agent/opa/v1/bundle"
+	"github.com/open-policy-agent/opa/v1/rego"
+	"github.com/sky1core/regobrick"
+)
+
+func TestLoadBundle_ParsedBundle(t *testing.T) {
+	ctx := context.Background()
+
+	roots := []string{"app"}
+	src := bundle.Bundle{
+		Manifest: bundle.Manifest{Roots: &roots},
+		Data:     map[string]any{"app": map[string]any{"admins": []any{"alice"}}},
+		Modules: []bundle.ModuleFile{{
+			Path: "/app/policy.rego",
+			URL:  "/app/policy.rego",
+			Raw: []byte(`package app
+import data.regobrick.default_false
+
+allow if input.user in data.app.admins
+`),
+		}},
+	}
+	var buf bytes.Buffer
+	if err := bundle.NewWriter(&buf).Write(src); err != nil {
+		t.Fatalf("writing bundle: %v", err)
+	}
+
+	b, err := regobrick.LoadBundle(&buf, regobrick.BundleOptions{})
+	if err != nil {
+		t.Fatalf("LoadBundle failed: %v", err)
+	}
+
+	query, err := rego.New(
+		rego.ParsedBundle("test", b),
+		rego.Query("data.app.allow"),
+	).PrepareForEval(ctx)
+	if err != nil {
+		t.Fatalf("PrepareForEval failed: %v", err)
+	}
+
+	for user, want := range map[string]bool{"alice": true, "bob": false} {
+		rs, err := query.Eval(ctx, rego.EvalInput(map[string]any{"user": user}))
+		if err != nil {
+			t.Fatalf("Eval failed: %v", err)
+		}
+		if len(rs) == 0 || rs[0].Expressions[0].Value != want {
+			t.Errorf("user %s: expected %v, got %v", user, want, rs)
+		}
+	}
+}
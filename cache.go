@@ -0,0 +1,33 @@
+package regobrick
+
+import "github.com/sky1core/regobrick/internal/module"
+
+// Cache is an alias for module.Cache. It keeps transformed modules keyed by
+// filename, source hash, imports and features, and hands out deep copies, so
+// queries rebuilt from the same files skip parsing. Set ModuleOption.Cache or
+// call ModuleSet.WithCache to use it.
+type Cache = module.Cache
+
+// CacheStats is an alias for module.CacheStats: hit, miss and eviction counts
+// and the current number of cached modules.
+type CacheStats = module.CacheStats
+
+// NewCache returns a Cache holding at most maxEntries transformed modules,
+// evicting the least recently used beyond that. It is safe for concurrent use
+// and panics if maxEntries is not positive.
+//
+// Example:
+//
+//	var policyCache = regobrick.NewCache(1000)
+//
+//	func newQuery(tenant string) *rego.Rego {
+//	    return rego.New(
+//	        regobrick.Modules(regobrick.ModuleOption{
+//	            Filename: "policy.rego", Source: policySrc, Cache: policyCache,
+//	        }),
+//	        rego.Query("data.policy.allow"),
+//	    )
+//	}
+func NewCache(maxEntries int) *Cache {
+	return module.NewCache(maxEntries)
+}
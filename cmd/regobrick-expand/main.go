@@ -0,0 +1,73 @@
+// Command regobrick-expand prints the effective Rego policy that regobrick
+// evaluates for a source file: the module after import injection and feature
+// transforms (e.g. default_false), formatted with OPA's formatter.
+//
+// Usage:
+//
+//	regobrick-expand [-import data.lib.money]... [-v0] [-o expanded.rego] policy.rego
+//
+// The expanded output can be committed next to the source for auditors and
+// diffed in code review.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/sky1core/regobrick"
+)
+
+// importList collects repeated -import flags.
+type importList []string
+
+func (l *importList) String() string { return strings.Join(*l, ",") }
+
+func (l *importList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+func main() {
+	var imports importList
+	flag.Var(&imports, "import", "import path to inject, e.g. data.lib.money or \"data.lib.v2.money as money2\" (repeatable)")
+	v0 := flag.Bool("v0", false, "parse and render the policy as Rego v0 syntax")
+	output := flag.String("o", "", "write the expanded policy to this file instead of stdout")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [-import path]... [-v0] [-o file] policy.rego\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	opts := regobrick.ParseOptions{Imports: imports}
+	if *v0 {
+		opts.RegoVersion = ast.RegoV0
+	}
+	if err := run(flag.Arg(0), opts, *output); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(filename string, opts regobrick.ParseOptions, output string) error {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	out, err := regobrick.FormatModuleWithOptions(filename, string(src), opts)
+	if err != nil {
+		return err
+	}
+	if output == "" {
+		_, err = os.Stdout.Write(out)
+		return err
+	}
+	return os.WriteFile(output, out, 0o644)
+}
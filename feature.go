@@ -0,0 +1,61 @@
+package regobrick
+
+import (
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/sky1core/regobrick/internal/module"
+)
+
+// FeatureOption is an alias for module.FeatureOption. It declares how a feature
+// registered with RegisterFeature is ordered relative to, or depends on, other
+// features.
+type FeatureOption = module.FeatureOption
+
+// RegisterFeature registers fn as the transform applied to every module that
+// contains "import data.regobrick.<name>" (or receives it as an injected import).
+//
+// A registered feature behaves like the built-in ones ("default_false",
+// "default_false_functions", "default_empty", "default_value", "decimal_literals",
+// "collect_reasons", "decimal_arithmetic"): the marker import passes the unknown-feature
+// validation and is stripped from the returned module, and an error returned by
+// fn makes ParseModule fail (and Module/Modules panic under their fail-fast
+// contract). A panic inside fn is converted into a ParseModule error.
+//
+// When a module enables several features, they run in a deterministic order
+// derived from FeatureAfter, FeatureBefore and FeatureRequires; features without
+// an ordering constraint between them run in name order.
+//
+// RegisterFeature panics if name is not a Rego identifier, fn is nil, or the
+// name is already registered. Must be called during package initialization
+// (init function). Calling after initialization may cause race conditions with
+// modules being parsed.
+//
+// Example:
+//
+//	func init() {
+//	    regobrick.RegisterFeature("require_package_doc", requirePackageDoc,
+//	        regobrick.FeatureAfter("default_false"))
+//	}
+func RegisterFeature(name string, fn func(*ast.Module) error, opts ...FeatureOption) {
+	module.RegisterFeature(name, fn, opts...)
+}
+
+// FeatureAfter runs the feature after the named features when they are enabled
+// in the same module. Naming a feature that is not enabled has no effect.
+func FeatureAfter(names ...string) FeatureOption {
+	return module.After(names...)
+}
+
+// FeatureBefore runs the feature before the named features when they are
+// enabled in the same module, e.g. a feature that synthesizes boolean rules can
+// run before "default_false" so those rules also get a default. Naming a feature
+// that is not enabled has no effect.
+func FeatureBefore(names ...string) FeatureOption {
+	return module.Before(names...)
+}
+
+// FeatureRequires enables the named features whenever the feature is enabled,
+// and runs the feature after them. ParseModule returns an error if a required
+// feature is not registered.
+func FeatureRequires(names ...string) FeatureOption {
+	return module.Requires(names...)
+}
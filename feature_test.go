@@ -0,0 +1,74 @@
+package regobrick_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/open-policy-agent/opa/v1/rego"
+	"github.com/sky1core/regobrick"
+)
+
+func init() {
+	// Adds a boolean "audited" rule; ordered before default_false so that the
+	// synthesized rule also receives a default.
+	regobrick.RegisterFeature("test_audited", func(mod *ast.Module) error {
+		mod.Rules = append(mod.Rules, ast.MustParseRule(`audited if input.audit_id`))
+		return nil
+	}, regobrick.FeatureBefore("default_false"))
+
+	regobrick.RegisterFeature("test_reject", func(*ast.Module) error {
+		return errors.New("policy rejected")
+	})
+}
+
+func TestRegisterFeature_PublicAPI(t *testing.T) {
+	ctx := context.Background()
+
+	policy := `
+		package test
+		import data.regobrick.test_audited
+		import data.regobrick.default_false
+	`
+
+	query, err := rego.New(
+		rego.Strict(true),
+		regobrick.Module("test.rego", policy, nil),
+		rego.Query("data.test.audited"),
+	).PrepareForEval(ctx)
+	if err != nil {
+		t.Fatalf("PrepareForEval failed: %v", err)
+	}
+
+	rs, err := query.Eval(ctx, rego.EvalInput(map[string]any{}))
+	if err != nil {
+		t.Fatalf("Eval failed: %v", err)
+	}
+	if len(rs) == 0 || rs[0].Expressions[0].Value != false {
+		t.Errorf("expected false, got %v", rs)
+	}
+}
+
+func TestRegisterFeature_ErrorFailsFast(t *testing.T) {
+	policy := `package test
+import data.regobrick.test_reject
+`
+	_, err := regobrick.ParseModule("test.rego", policy, nil)
+	if err == nil || !strings.Contains(err.Error(), "policy rejected") {
+		t.Fatalf("expected transform error from ParseModule, got: %v", err)
+	}
+
+	defer func() {
+		rec := recover()
+		if rec == nil {
+			t.Fatal("expected panic for failing feature transform, got none")
+		}
+		msg, _ := rec.(string)
+		if !strings.Contains(msg, "cannot process module") || !strings.Contains(msg, "test_reject") {
+			t.Errorf("panic message should mention cause and feature, got: %v", rec)
+		}
+	}()
+	regobrick.Module("test.rego", policy, nil)(rego.New())
+}
@@ -1,15 +1,19 @@
-// Package benchcommon holds the policy sources, inputs and runner shared by the
-// standard-OPA and decimal-mode benchmark packages.
+// Package bench compares policy evaluation with standard OPA builtins, with
+// UseDecimalArithmetic, and with UseDecimalArithmetic(WithStringCoercion()) over
+// string-typed numeric inputs, in a single test binary.
 //
-// The two modes must live in separate test binaries because
-// UseDecimalArithmetic mutates OPA's process-global builtin registry and cannot
-// be undone. Identical benchmark names across the two packages allow direct
-// comparison, e.g.:
+// Every benchmark runs one sub-benchmark per mode. The decimal modes enable
+// decimal arithmetic for their own run and call RestoreStandardArithmetic when
+// it ends, which puts OPA's original implementations back into the builtin
+// registry, so the standard runs measure stock OPA. The modes can be compared
+// with benchstat, e.g.:
 //
-//	go test -bench . -count 10 ./internal/bench/standard > standard.txt
-//	go test -bench . -count 10 ./internal/bench/decimal > decimal.txt
-//	benchstat standard.txt decimal.txt
-package benchcommon
+//	go test -run '^$' -bench . -count 10 ./internal/bench > bench.txt
+//	benchstat -col /mode bench.txt
+//
+// The coercion mode measures a different workload by design: its numbers arrive
+// as JSON strings and go through the coercion path.
+package bench
 
 import (
 	"context"
@@ -0,0 +1,59 @@
+package bench
+
+import (
+	"testing"
+
+	"github.com/sky1core/regobrick"
+)
+
+// mode is a configuration the benchmarks are run under.
+type mode struct {
+	name string
+	// enable switches the mode on, or is nil for standard OPA.
+	enable func()
+	inputs func(n int) map[string]any
+}
+
+var modes = []mode{
+	{name: "standard", inputs: Inputs},
+	{name: "decimal", enable: func() { regobrick.UseDecimalArithmetic() }, inputs: Inputs},
+	{
+		name:   "coercion",
+		enable: func() { regobrick.UseDecimalArithmetic(regobrick.WithStringCoercion()) },
+		inputs: StringInputs,
+	},
+}
+
+// runModes runs policy over n input elements once per mode, as the
+// sub-benchmarks "mode=<name>".
+func runModes(b *testing.B, policy string, n int) {
+	for _, m := range modes {
+		b.Run("mode="+m.name, func(b *testing.B) {
+			if m.enable != nil {
+				m.enable()
+				b.Cleanup(regobrick.RestoreStandardArithmetic)
+			}
+			RunPolicyBenchmark(b, policy, m.inputs(n))
+		})
+	}
+}
+
+func BenchmarkArithSum1000(b *testing.B) {
+	runModes(b, ArithSumPolicy, 1000)
+}
+
+func BenchmarkCompareFilter1000(b *testing.B) {
+	runModes(b, CompareFilterPolicy, 1000)
+}
+
+func BenchmarkAggregates1000(b *testing.B) {
+	runModes(b, AggregatesPolicy, 1000)
+}
+
+func BenchmarkDivSum1000(b *testing.B) {
+	runModes(b, DivSumPolicy, 1000)
+}
+
+func BenchmarkScalarGuard(b *testing.B) {
+	runModes(b, ScalarGuardPolicy, 1)
+}
@@ -0,0 +1,48 @@
+package bench
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/open-policy-agent/opa/v1/rego"
+	"github.com/sky1core/regobrick"
+)
+
+// TestDecimalArithmeticIsQueryScoped checks that regobrick.DecimalArithmetic
+// leaves the stock builtins untouched while the process-global decimal mode is
+// off: a decimal query and a standard query over the same policy evaluate side
+// by side.
+func TestDecimalArithmeticIsQueryScoped(t *testing.T) {
+	ctx := context.Background()
+	const policy = "package test\n\nresult := input.a + input.b\n"
+	input := rego.Input(map[string]any{"a": json.Number("1.1"), "b": json.Number("2.2")})
+
+	eval := func(opts ...func(*rego.Rego)) any {
+		t.Helper()
+		rs, err := rego.New(append(opts, rego.Query("data.test.result"), input)...).Eval(ctx)
+		if err != nil || len(rs) != 1 {
+			t.Fatalf("eval: %v, %v", rs, err)
+		}
+		return rs[0].Expressions[0].Value
+	}
+
+	for i := 0; i < 2; i++ {
+		decimal := eval(
+			regobrick.DecimalArithmetic(),
+			regobrick.Modules(regobrick.ModuleOption{
+				Filename: "test.rego",
+				Source:   policy,
+				Features: []string{"decimal_arithmetic"},
+			}),
+		)
+		if decimal != json.Number("3.3") {
+			t.Errorf("decimal query: expected 3.3, got %v", decimal)
+		}
+
+		standard := eval(rego.Module("test.rego", policy))
+		if standard == json.Number("3.3") {
+			t.Errorf("standard query: expected big.Float residue, got %v", standard)
+		}
+	}
+}
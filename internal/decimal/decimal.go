@@ -0,0 +1,120 @@
+// Package decimal holds the number parsing shared by the decimal operators of the
+// regobrick package and the decimal_literals module feature, so that both agree
+// on which numbers udecimal can represent, and the list of operators shared by
+// the decimal operators and the decimal_arithmetic module feature.
+package decimal
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/quagmt/udecimal"
+)
+
+// MaxExpandedLen is the upper bound on the string length of an expanded exponent
+// notation result. It is an allocation guard, not a udecimal limit: without it a
+// huge exponent from an input path (e.g. 1e2000000000) would trigger ~2GB of
+// zero-string allocation via strings.Repeat. When the bound is exceeded the
+// original string is returned unchanged and udecimal.Parse rejects the exponent
+// notation as an invalid format.
+//
+// Consequence: exponent-notation inputs are capped at a ~62-digit expansion
+// (1e61 parses; 1e62 and beyond, e.g. 1e100, fail) even though udecimal itself
+// could represent them via its big.Int fallback. The same magnitude written in
+// plain notation is unaffected by this cap. The bound comfortably covers the
+// u128 fast path (~39 significant digits, up to 19 of them fractional).
+const MaxExpandedLen = 64
+
+// ExpandExponent expands a scientific-notation number string into plain decimal
+// notation. E.g. "1e-8"→"0.00000001", "-2.5E+3"→"-2500", "1.5e0"→"1.5".
+//
+// It shifts only the decimal point via pure string manipulation without going
+// through float64, so there is no precision loss. Non-exponent (e.g. "123.45")
+// or malformed strings are returned unchanged for udecimal.Parse to decide on.
+//
+// When the expanded result exceeds udecimal's precision (19 fractional digits,
+// e.g. "1e-25"→"0.0000...1"), udecimal.Parse returns an error; this is the
+// documented precision limit. An exponent whose expansion would exceed
+// MaxExpandedLen (e.g. "1e100", "1e2000000000") is not expanded and the
+// original string is returned to avoid an allocation blowup; udecimal then
+// rejects the un-expanded exponent notation as an invalid format.
+func ExpandExponent(s string) string {
+	ePos := strings.IndexAny(s, "eE")
+	if ePos < 0 {
+		return s
+	}
+
+	mantissa := s[:ePos]
+	exp, err := strconv.Atoi(s[ePos+1:])
+	if err != nil {
+		return s // exponent is not an integer (including out-of-int-range) → let udecimal reject it
+	}
+
+	// Size guard 1: if the absolute exponent exceeds the bound, the expansion is
+	// guaranteed to exceed it too, so do not expand. (This also blocks integer
+	// overflow in the later newExp computation.)
+	if exp > MaxExpandedLen || exp < -MaxExpandedLen {
+		return s
+	}
+
+	// Separate the sign of the mantissa.
+	sign := ""
+	if len(mantissa) > 0 && (mantissa[0] == '+' || mantissa[0] == '-') {
+		if mantissa[0] == '-' {
+			sign = "-"
+		}
+		mantissa = mantissa[1:]
+	}
+
+	// Separate the integer and fractional parts.
+	intPart := mantissa
+	fracPart := ""
+	if dot := strings.IndexByte(mantissa, '.'); dot >= 0 {
+		intPart = mantissa[:dot]
+		fracPart = mantissa[dot+1:]
+	}
+
+	digits := intPart + fracPart
+	if digits == "" {
+		return s
+	}
+	for i := 0; i < len(digits); i++ {
+		if digits[i] < '0' || digits[i] > '9' {
+			return s // non-digit character in the mantissa → let udecimal reject it
+		}
+	}
+
+	// value = digits × 10^(exp - len(fracPart))
+	newExp := exp - len(fracPart)
+
+	// Size guard 2: precompute the expansion length and do not expand if it
+	// exceeds the bound.
+	// (newExp >= 0: len(digits)+newExp, newExp < 0: at most len(digits)+|newExp|+2)
+	absNewExp := newExp
+	if absNewExp < 0 {
+		absNewExp = -absNewExp
+	}
+	if len(digits)+absNewExp+2 > MaxExpandedLen {
+		return s
+	}
+
+	var out string
+	if newExp >= 0 {
+		out = digits + strings.Repeat("0", newExp)
+	} else {
+		k := -newExp
+		if len(digits) > k {
+			out = digits[:len(digits)-k] + "." + digits[len(digits)-k:]
+		} else {
+			out = "0." + strings.Repeat("0", k-len(digits)) + digits
+		}
+	}
+	return sign + out
+}
+
+// Parse parses a numeric string into a udecimal.Decimal.
+// It first expands exponent notation into plain decimal notation before passing
+// it to udecimal.Parse, so exponent notation like "1e-8" is parsed accurately.
+func Parse(s string) (udecimal.Decimal, error) {
+	return udecimal.Parse(ExpandExponent(s))
+}
@@ -0,0 +1,34 @@
+package decimal
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"123.45", "123.45", false},
+		{"1e-8", "0.00000001", false},
+		{"-2.5E+3", "-2500", false},
+		{"1e-25", "", true},
+		{"1e100", "", true},
+		{"abc", "", true},
+	}
+	for _, tt := range tests {
+		d, err := Parse(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Parse(%q): expected error, got %s", tt.in, d)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q) error: %v", tt.in, err)
+			continue
+		}
+		if got := d.String(); got != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
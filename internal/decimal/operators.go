@@ -0,0 +1,67 @@
+package decimal
+
+import (
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/open-policy-agent/opa/v1/types"
+)
+
+// ScopedPrefix is the namespace of the query-scoped decimal builtins. The
+// decimal_arithmetic module feature rewrites a call to an operator in Operators,
+// e.g. plus, into a call to ScopedPrefix + "plus", which regobrick.DecimalArithmetic
+// registers for a single query.
+const ScopedPrefix = "regobrick.decimal."
+
+// Operators lists the names of the OPA builtins that decimal arithmetic
+// overloads, both process-wide (UseDecimalArithmetic) and per query
+// (DecimalArithmetic).
+var Operators = []string{
+	// Arithmetic operators
+	ast.Plus.Name,
+	ast.Minus.Name,
+	ast.Multiply.Name,
+	ast.Divide.Name,
+	ast.Rem.Name,
+
+	// Comparison operators
+	ast.GreaterThan.Name,
+	ast.GreaterThanEq.Name,
+	ast.LessThan.Name,
+	ast.LessThanEq.Name,
+	ast.Equal.Name,
+	ast.NotEqual.Name,
+
+	// Unary operators
+	ast.Abs.Name,
+	ast.Round.Name,
+	ast.Ceil.Name,
+	ast.Floor.Name,
+
+	// Aggregate operators
+	ast.Sum.Name,
+	ast.Product.Name,
+	ast.Max.Name,
+	ast.Min.Name,
+}
+
+// Div is the name of the decimal division builtin with an explicit scale,
+// decimal.div(x, y, scale). It is not an OPA builtin: UseDecimalArithmetic
+// registers it process-wide and DecimalArithmetic per query, under
+// ScopedName(Div).
+const Div = "decimal.div"
+
+// DivDecl is the declaration of Div.
+var DivDecl = types.NewFunction(
+	types.Args(
+		types.Named("x", types.N).Description("the dividend"),
+		types.Named("y", types.N).Description("the divisor"),
+		types.Named("scale", types.N).Description("the maximum number of fractional digits of the quotient"),
+	),
+	types.Named("z", types.N).Description("the quotient of `x` divided by `y`, with at most `scale` fractional digits"),
+)
+
+// ScopedName returns the name of the query-scoped builtin for the operator op,
+// e.g. "regobrick.decimal.plus" for "plus" and "regobrick.decimal.decimal.div"
+// for Div.
+func ScopedName(op string) string {
+	return ScopedPrefix + op
+}
@@ -0,0 +1,126 @@
+package decimal
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/quagmt/udecimal"
+)
+
+// MaxExponent bounds the exponent ParseRat accepts, in either direction. Like
+// MaxExpandedLen it is an allocation guard: 1e2000000000 would otherwise build a
+// two-billion-digit integer.
+const MaxExponent = 1000
+
+// ParseRat parses a numeric string into an exact *big.Rat. It accepts the plain
+// and exponent notations of a JSON number, with any number of digits (e.g.
+// "1e-25", "0.000000000000000000000001"), and an optional leading "+". Unlike
+// big.Rat.SetString it rejects fractions ("1/3"), base prefixes and special
+// values.
+func ParseRat(s string) (*big.Rat, error) {
+	mantissa, exp := s, 0
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		e, err := strconv.Atoi(s[i+1:])
+		if err != nil || s[i+1:] == "" {
+			return nil, fmt.Errorf("invalid decimal %q", s)
+		}
+		if e > MaxExponent || e < -MaxExponent {
+			return nil, fmt.Errorf("exponent of %q exceeds ±%d", s, MaxExponent)
+		}
+		mantissa, exp = s[:i], e
+	}
+	if !validMantissa(mantissa) {
+		return nil, fmt.Errorf("invalid decimal %q", s)
+	}
+	r, ok := new(big.Rat).SetString(mantissa)
+	if !ok {
+		return nil, fmt.Errorf("invalid decimal %q", s)
+	}
+	if exp != 0 {
+		pow := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(exp))), nil))
+		if exp > 0 {
+			r.Mul(r, pow)
+		} else {
+			r.Quo(r, pow)
+		}
+	}
+	return r, nil
+}
+
+// validMantissa reports whether s is an optionally signed decimal number with
+// digits on both sides of an optional decimal point.
+func validMantissa(s string) bool {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "-"), "+")
+	intPart, frac, hasPoint := strings.Cut(s, ".")
+	return allDigits(intPart) && (!hasPoint || allDigits(frac))
+}
+
+func allDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// FormatRat formats r in plain notation without trailing zeros, e.g. "0.5" or
+// "-12". r must have a finite decimal expansion, as every result of the decimal
+// operators has; other values are cut at MaxScale fractional digits.
+func FormatRat(r *big.Rat) string {
+	if r.IsInt() {
+		return r.Num().String()
+	}
+	// The expansion is finite iff the denominator is 2^a * 5^b; it then has
+	// max(a, b) fractional digits.
+	den := new(big.Int).Set(r.Denom())
+	twos := int(den.TrailingZeroBits())
+	den.Rsh(den, uint(twos))
+	fives := 0
+	five := big.NewInt(5)
+	for q, m := new(big.Int), new(big.Int); ; fives++ {
+		q.QuoRem(den, five, m)
+		if m.Sign() != 0 {
+			break
+		}
+		den.Set(q)
+	}
+	if den.Cmp(big.NewInt(1)) != 0 {
+		return trimZeros(r.FloatString(MaxScale))
+	}
+	scale := max(twos, fives)
+	pow := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)
+	q := new(big.Int).Mul(r.Num(), pow)
+	q.Quo(q, r.Denom())
+	return trimZeros(formatScaled(q, scale))
+}
+
+// trimZeros drops the trailing fractional zeros of a plain-notation number.
+func trimZeros(s string) string {
+	if !strings.Contains(s, ".") {
+		return s
+	}
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// FromRat converts r, which must have a finite decimal expansion, into a
+// udecimal.Decimal. It fails if r has more than MaxScale fractional digits.
+func FromRat(r *big.Rat) (udecimal.Decimal, error) {
+	return udecimal.Parse(FormatRat(r))
+}
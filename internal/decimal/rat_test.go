@@ -0,0 +1,46 @@
+package decimal
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRat(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"123.45", "123.45", false},
+		{"-2.5E+3", "-2500", false},
+		{"+1.50", "1.5", false},
+		{"1e-25", "0.0000000000000000000000001", false},
+		{"0.000000000000000000000001", "0.000000000000000000000001", false},
+		{"1e100", "1" + strings.Repeat("0", 100), false},
+		{"1e1001", "", true},
+		{"1e-1001", "", true},
+		{"1/3", "", true},
+		{"0x10", "", true},
+		{"1.", "", true},
+		{".5", "", true},
+		{"1e", "", true},
+		{"abc", "", true},
+		{"", "", true},
+	}
+	for _, tt := range tests {
+		r, err := ParseRat(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseRat(%q): expected error, got %s", tt.in, r)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseRat(%q) error: %v", tt.in, err)
+			continue
+		}
+		if got := FormatRat(r); got != tt.want {
+			t.Errorf("ParseRat(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package decimal
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/quagmt/udecimal"
+)
+
+// MaxScale is the number of fractional digits udecimal represents. Division and
+// multiplication results are rounded (or, by default, truncated) to it.
+const MaxScale = 19
+
+// RoundingMode selects how an inexact result is rounded to its scale. The names
+// follow java.math.RoundingMode; "up" and "down" are away from and toward zero.
+type RoundingMode int
+
+const (
+	// RoundDown rounds toward zero (truncation).
+	RoundDown RoundingMode = iota + 1
+	// RoundUp rounds away from zero.
+	RoundUp
+	// RoundCeiling rounds toward positive infinity.
+	RoundCeiling
+	// RoundFloor rounds toward negative infinity.
+	RoundFloor
+	// RoundHalfUp rounds to the nearest neighbor, ties away from zero.
+	RoundHalfUp
+	// RoundHalfDown rounds to the nearest neighbor, ties toward zero.
+	RoundHalfDown
+	// RoundHalfEven rounds to the nearest neighbor, ties to the even neighbor
+	// (banker's rounding).
+	RoundHalfEven
+)
+
+var roundingModeNames = map[RoundingMode]string{
+	RoundDown:     "down",
+	RoundUp:       "up",
+	RoundCeiling:  "ceiling",
+	RoundFloor:    "floor",
+	RoundHalfUp:   "half_up",
+	RoundHalfDown: "half_down",
+	RoundHalfEven: "half_even",
+}
+
+// String returns the name of m, e.g. "half_even".
+func (m RoundingMode) String() string {
+	if name, ok := roundingModeNames[m]; ok {
+		return name
+	}
+	return fmt.Sprintf("RoundingMode(%d)", int(m))
+}
+
+// Valid reports whether m is one of the declared rounding modes.
+func (m RoundingMode) Valid() bool {
+	_, ok := roundingModeNames[m]
+	return ok
+}
+
+// Rat returns the exact value of d.
+func Rat(d udecimal.Decimal) *big.Rat {
+	r, _ := new(big.Rat).SetString(d.String())
+	return r
+}
+
+// RoundRat returns r rounded to scale fractional digits with mode.
+func RoundRat(r *big.Rat, scale int, mode RoundingMode) *big.Rat {
+	pow := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)
+	if r.IsInt() || new(big.Int).Rem(pow, r.Denom()).Sign() == 0 {
+		// Already at most scale fractional digits.
+		return r
+	}
+	num := new(big.Int).Mul(r.Num(), pow)
+	q, rem := new(big.Int).QuoRem(num, r.Denom(), new(big.Int))
+	if rem.Sign() != 0 && roundsAway(mode, r.Sign(), q, rem, r.Denom()) {
+		if r.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return new(big.Rat).SetFrac(q, pow)
+}
+
+// roundsAway reports whether the truncated quotient q, with the non-zero
+// remainder rem of a division by den, must be moved one unit away from zero. sign
+// is the sign of the exact value.
+func roundsAway(mode RoundingMode, sign int, q, rem, den *big.Int) bool {
+	switch mode {
+	case RoundDown:
+		return false
+	case RoundUp:
+		return true
+	case RoundCeiling:
+		return sign > 0
+	case RoundFloor:
+		return sign < 0
+	}
+	twice := new(big.Int).Abs(rem)
+	twice.Lsh(twice, 1)
+	switch twice.Cmp(den) {
+	case 1:
+		return true
+	case -1:
+		return false
+	}
+	switch mode {
+	case RoundHalfUp:
+		return true
+	case RoundHalfEven:
+		return new(big.Int).Abs(q).Bit(0) == 1
+	default:
+		return false
+	}
+}
+
+// formatScaled formats q * 10^-scale in plain notation.
+func formatScaled(q *big.Int, scale int) string {
+	s := new(big.Int).Abs(q).String()
+	if scale > 0 {
+		if len(s) <= scale {
+			s = strings.Repeat("0", scale-len(s)+1) + s
+		}
+		s = s[:len(s)-scale] + "." + s[len(s)-scale:]
+	}
+	if q.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
@@ -0,0 +1,48 @@
+package decimal
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestRoundRat(t *testing.T) {
+	tests := []struct {
+		in    string
+		scale int
+		mode  RoundingMode
+		want  string
+	}{
+		{"2/3", 19, RoundDown, "0.6666666666666666666"},
+		{"2/3", 19, RoundHalfEven, "0.6666666666666666667"},
+		{"-2/3", 19, RoundCeiling, "-0.6666666666666666666"},
+		{"-2/3", 19, RoundFloor, "-0.6666666666666666667"},
+		{"5/2", 0, RoundHalfUp, "3"},
+		{"5/2", 0, RoundHalfDown, "2"},
+		{"5/2", 0, RoundHalfEven, "2"},
+		{"7/2", 0, RoundHalfEven, "4"},
+		{"-5/2", 0, RoundHalfUp, "-3"},
+		{"-5/2", 0, RoundHalfEven, "-2"},
+		{"1/100", 1, RoundUp, "0.1"},
+		{"-1/100", 1, RoundDown, "0"},
+		{"-1/100", 1, RoundFloor, "-0.1"},
+		{"123/10", 1, RoundHalfEven, "12.3"},
+	}
+	for _, tt := range tests {
+		r, ok := new(big.Rat).SetString(tt.in)
+		if !ok {
+			t.Fatalf("bad rational %q", tt.in)
+		}
+		if got := FormatRat(RoundRat(r, tt.scale, tt.mode)); got != tt.want {
+			t.Errorf("RoundRat(%s, %d, %v) = %s, want %s", tt.in, tt.scale, tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestRoundingMode_String(t *testing.T) {
+	if got := RoundHalfEven.String(); got != "half_even" {
+		t.Errorf("String() = %q, want half_even", got)
+	}
+	if RoundingMode(0).Valid() || RoundingMode(42).Valid() {
+		t.Error("expected undeclared modes to be invalid")
+	}
+}
@@ -0,0 +1,82 @@
+package module
+
+import (
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/sky1core/regobrick/internal/decimal"
+)
+
+// featureDecimalArithmetic is the regobrick feature that routes the operators of
+// a module to the query-scoped decimal builtins.
+const featureDecimalArithmetic = "decimal_arithmetic"
+
+// scopedDecimalRefs maps each operator overloaded by decimal arithmetic, and
+// decimal.div, to the ref of its query-scoped builtin, e.g. plus to
+// regobrick.decimal.plus.
+var scopedDecimalRefs = func() map[string]ast.Ref {
+	refs := make(map[string]ast.Ref, len(decimal.Operators)+1)
+	for _, op := range append([]string{decimal.Div}, decimal.Operators...) {
+		refs[op] = ast.MustParseRef(decimal.ScopedName(op))
+	}
+	return refs
+}()
+
+// rewriteDecimalOperators replaces every call in mod to an operator overloaded by
+// decimal arithmetic (+, -, *, /, %, the comparisons, abs, round, ceil, floor,
+// sum, product, max and min), and to decimal.div, with a call to its
+// "regobrick.decimal.*" builtin, e.g. "a + b" becomes
+// "regobrick.decimal.plus(a, b)". Those builtins are only
+// defined in queries built with regobrick.DecimalArithmetic, so a rewritten
+// module does not compile without it instead of silently falling back to
+// standard arithmetic. Other modules and queries keep OPA's builtins.
+//
+// A call whose first name the module binds itself is left alone: a rule or
+// function such as "max(a, b) := ...", or an import such as
+// "import data.lib.round", shadows the builtin of the same name.
+func rewriteDecimalOperators(mod *ast.Module) error {
+	local := localNames(mod)
+	rewrite := func(op *ast.Term) {
+		ref, ok := op.Value.(ast.Ref)
+		if !ok || len(ref) == 0 {
+			return
+		}
+		if name, ok := ref[0].Value.(ast.Var); ok && local[name] {
+			return
+		}
+		if scoped, ok := scopedDecimalRefs[ref.String()]; ok {
+			op.Value = scoped.Copy()
+		}
+	}
+
+	// Calls in statement position, e.g. "a > b".
+	ast.WalkExprs(mod, func(expr *ast.Expr) bool {
+		if terms, ok := expr.Terms.([]*ast.Term); ok && len(terms) > 0 {
+			rewrite(terms[0])
+		}
+		return false
+	})
+	// Calls nested in terms, e.g. "x := a + b".
+	ast.WalkTerms(mod, func(t *ast.Term) bool {
+		if call, ok := t.Value.(ast.Call); ok && len(call) > 0 {
+			rewrite(call[0])
+		}
+		return false
+	})
+	return nil
+}
+
+// localNames returns the names mod binds at its top level: the first segment
+// of every rule and function ref, and the name of every import.
+func localNames(mod *ast.Module) map[ast.Var]bool {
+	names := make(map[ast.Var]bool)
+	for _, r := range mod.Rules {
+		if ref := r.Head.Ref(); len(ref) > 0 {
+			if name, ok := ref[0].Value.(ast.Var); ok {
+				names[name] = true
+			}
+		}
+	}
+	for _, imp := range mod.Imports {
+		names[imp.Name()] = true
+	}
+	return names
+}
@@ -0,0 +1,120 @@
+package module
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+)
+
+func TestRewriteDecimalOperators(t *testing.T) {
+	source := `package pricing
+import data.regobrick.decimal_arithmetic
+
+total := input.price * input.qty + input.fee
+
+cheap if input.price < 10
+
+discounted contains p if {
+	some item in input.items
+	p := round(item.price - item.discount)
+	not p >= 100
+}
+
+rounded(x) := floor(x / 2)
+
+ratio := decimal.div(input.a, input.b, 2)
+
+count_ok if count(input.items) == 2
+`
+	mod, err := ParseModule("pricing.rego", source, nil)
+	if err != nil {
+		t.Fatalf("ParseModule error: %v", err)
+	}
+
+	calls := map[string]bool{}
+	record := func(op *ast.Term) {
+		calls[op.String()] = true
+	}
+	ast.WalkExprs(mod, func(expr *ast.Expr) bool {
+		if expr.IsCall() {
+			record(expr.Terms.([]*ast.Term)[0])
+		}
+		return false
+	})
+	ast.WalkTerms(mod, func(term *ast.Term) bool {
+		if call, ok := term.Value.(ast.Call); ok {
+			record(call[0])
+		}
+		return false
+	})
+
+	for _, want := range []string{
+		"regobrick.decimal.mul", "regobrick.decimal.plus", "regobrick.decimal.lt",
+		"regobrick.decimal.round", "regobrick.decimal.minus", "regobrick.decimal.gte",
+		"regobrick.decimal.floor", "regobrick.decimal.div", "regobrick.decimal.equal",
+		"regobrick.decimal.decimal.div",
+	} {
+		if !calls[want] {
+			t.Errorf("expected a call to %s, got %v", want, calls)
+		}
+	}
+	for _, unwanted := range []string{"mul", "plus", "lt", "round", "minus", "gte", "floor", "div", "equal", "decimal.div"} {
+		if calls[unwanted] {
+			t.Errorf("expected %s to be rewritten, got %v", unwanted, calls)
+		}
+	}
+	// Builtins that decimal arithmetic does not overload are left alone.
+	if !calls["count"] || !calls["internal.member_2"] {
+		t.Errorf("expected count and membership calls to be kept, got %v", calls)
+	}
+}
+
+func TestRewriteDecimalOperators_OnlyWhenEnabled(t *testing.T) {
+	mod, err := ParseModuleWithOptions("pricing.rego", "package pricing\n\ntotal := input.a + input.b\n", ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseModuleWithOptions error: %v", err)
+	}
+	if got := mod.Rules[0].Head.Value.String(); got != "plus(input.a, input.b)" {
+		t.Errorf("expected the standard operator without the feature, got %s", got)
+	}
+
+	out, err := FormatModuleWithOptions("pricing.rego", "package pricing\n\ntotal := input.a + input.b\n",
+		ParseOptions{Features: []string{featureDecimalArithmetic}})
+	if err != nil {
+		t.Fatalf("FormatModuleWithOptions error: %v", err)
+	}
+	if !strings.Contains(string(out), "total := regobrick.decimal.plus(input.a, input.b)") {
+		t.Errorf("expected the scoped builtin in the rendered policy, got:\n%s", out)
+	}
+}
+
+// TestRewriteDecimalOperators_LocalNames checks that calls to functions the
+// module defines or imports under the name of an overloaded builtin are kept.
+func TestRewriteDecimalOperators_LocalNames(t *testing.T) {
+	source := `package pricing
+import data.regobrick.decimal_arithmetic
+import data.lib.round
+
+max(a, b) := a if a > b
+max(a, b) := b if a <= b
+
+bigger := max(input.a, input.b)
+rounded := round(input.a)
+total := sum([input.a, input.b])
+`
+	out, err := FormatModule("pricing.rego", source, nil)
+	if err != nil {
+		t.Fatalf("FormatModule error: %v", err)
+	}
+	for _, want := range []string{
+		"bigger := max(input.a, input.b)",
+		"rounded := round(input.a)",
+		"total := regobrick.decimal.sum([input.a, input.b])",
+		"max(a, b) := a if regobrick.decimal.gt(a, b)",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("expected %q in the rendered policy, got:\n%s", want, out)
+		}
+	}
+}
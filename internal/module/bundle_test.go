@@ -0,0 +1,109 @@
+package module
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/open-policy-agent/opa/v1/bundle"
+)
+
+func writeTestBundle(t *testing.T, modules map[string]string) *bytes.Buffer {
+	t.Helper()
+	roots := []string{"app", "lib"}
+	b := bundle.Bundle{
+		Manifest: bundle.Manifest{Roots: &roots, Revision: "rev-1"},
+		Data:     map[string]any{"app": map[string]any{"limit": 10}},
+	}
+	for path, src := range modules {
+		b.Modules = append(b.Modules, bundle.ModuleFile{Path: path, URL: path, Raw: []byte(src)})
+	}
+	var buf bytes.Buffer
+	if err := bundle.NewWriter(&buf).Write(b); err != nil {
+		t.Fatalf("writing test bundle: %v", err)
+	}
+	return &buf
+}
+
+func TestLoadBundle_TransformsModules(t *testing.T) {
+	buf := writeTestBundle(t, map[string]string{
+		"/app/billing/policy.rego": "package app.billing\nimport data.regobrick.default_false\n\nallow if money.positive(input.amount)\n",
+		"/lib/money.rego":          "package lib.money\n\npositive(x) if x > 0\n",
+	})
+
+	b, err := LoadBundle(buf, BundleOptions{Rules: []ImportRule{ImportsForPackage("app.**", "data.lib.money")}})
+	if err != nil {
+		t.Fatalf("LoadBundle error: %v", err)
+	}
+	if b.Manifest.Revision != "rev-1" || b.Manifest.Roots == nil || len(*b.Manifest.Roots) != 2 {
+		t.Errorf("expected manifest to be kept, got %+v", b.Manifest)
+	}
+	if b.Data["app"] == nil {
+		t.Errorf("expected data to be kept, got %v", b.Data)
+	}
+
+	for _, mf := range b.Modules {
+		if !strings.HasSuffix(mf.Path, "policy.rego") {
+			continue
+		}
+		hasDefault, hasImport := false, false
+		for _, r := range mf.Parsed.Rules {
+			if r.Default && r.Head.Ref().String() == "allow" {
+				hasDefault = true
+			}
+		}
+		for _, imp := range mf.Parsed.Imports {
+			if imp.Path.String() == "data.lib.money" {
+				hasImport = true
+			}
+			if strings.HasPrefix(imp.Path.String(), "data.regobrick.") {
+				t.Errorf("expected marker import to be stripped, got %v", imp)
+			}
+		}
+		if !hasDefault || !hasImport {
+			t.Errorf("expected default_false and injected import, got default=%v import=%v", hasDefault, hasImport)
+		}
+	}
+}
+
+func TestLoadBundle_CollectsModuleErrors(t *testing.T) {
+	buf := writeTestBundle(t, map[string]string{
+		"/app/a.rego": "package app.a\nimport data.regobrick.default_flase\n",
+		"/app/b.rego": "package app.b\nimport data.regobrick.nope\n",
+		"/app/c.rego": "package app.c\n\nallow if input.x\n",
+	})
+	_, err := LoadBundle(buf, BundleOptions{})
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	var me *ModuleError
+	if !errors.As(err, &me) {
+		t.Fatalf("expected *ModuleError, got %T", err)
+	}
+	for _, name := range []string{"a.rego", "b.rego"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("error should name %s, got: %v", name, err)
+		}
+	}
+}
+
+func TestLoadBundleFile_Directory(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "policy.rego"), []byte("package app\n\nallow if input.x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	b, err := LoadBundleFile(dir, BundleOptions{Features: []string{featureDefaultFalse}})
+	if err != nil {
+		t.Fatalf("LoadBundleFile error: %v", err)
+	}
+	if len(b.Modules) != 1 || len(b.Modules[0].Parsed.Rules) != 2 {
+		t.Fatalf("expected one module with a synthesized default, got %+v", b.Modules)
+	}
+
+	if _, err := LoadBundleFile(filepath.Join(dir, "missing.tar.gz"), BundleOptions{}); err == nil {
+		t.Error("expected error for a missing bundle, got nil")
+	}
+}
@@ -0,0 +1,139 @@
+package module
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+)
+
+// Cache holds transformed modules keyed by filename, a hash of the source, the
+// injected imports, the enabled features and the other ParseOptions, so that
+// rebuilding queries from the same files does not parse and transform them again.
+// It is exposed publicly as regobrick.Cache.
+//
+// Modules are returned as deep copies: callers (and the OPA compiler, which
+// rewrites modules in place) never share a value with the cache or with each
+// other. A Cache is safe for concurrent use. When it holds maxEntries modules,
+// adding one evicts the least recently used.
+//
+// Parse errors are not cached. Features are looked up in the registry at parse
+// time, so a Cache must not be used before every feature has been registered.
+type Cache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[cacheKey]*list.Element
+	lru        *list.List // of *cacheEntry, most recently used first
+	stats      CacheStats
+}
+
+// CacheStats reports the activity of a Cache. It is exposed publicly as
+// regobrick.CacheStats.
+type CacheStats struct {
+	// Hits counts lookups served from the cache.
+	Hits uint64 `json:"hits"`
+	// Misses counts lookups that parsed the module.
+	Misses uint64 `json:"misses"`
+	// Evictions counts modules dropped to respect the size bound.
+	Evictions uint64 `json:"evictions"`
+	// Entries is the number of modules currently cached.
+	Entries int `json:"entries"`
+}
+
+// cacheKey identifies a transformed module. Slices are joined with a NUL byte,
+// which cannot appear in a filename, import path or feature name.
+type cacheKey struct {
+	filename      string
+	sourceHash    [sha256.Size]byte
+	imports       string
+	features      string
+	regoVersion   ast.RegoVersion
+	forbidMarkers bool
+	bigLiterals   bool
+}
+
+type cacheEntry struct {
+	key cacheKey
+	mod *ast.Module
+}
+
+// NewCache returns a Cache holding at most maxEntries modules. It panics if
+// maxEntries is not positive.
+func NewCache(maxEntries int) *Cache {
+	if maxEntries <= 0 {
+		panic(fmt.Sprintf("regobrick: cache size must be positive, got %d", maxEntries))
+	}
+	return &Cache{
+		maxEntries: maxEntries,
+		entries:    make(map[cacheKey]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// ParseModuleWithOptions returns a deep copy of the module ParseModuleWithOptions
+// produces for filename, source and opts, parsing it only on a cache miss.
+// Features are keyed as a set: their order in opts.Features does not matter.
+func (c *Cache) ParseModuleWithOptions(filename, source string, opts ParseOptions) (*ast.Module, error) {
+	key := newCacheKey(filename, source, opts)
+
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(el)
+		c.stats.Hits++
+		mod := el.Value.(*cacheEntry).mod
+		c.mu.Unlock()
+		return mod.Copy(), nil
+	}
+	c.stats.Misses++
+	c.mu.Unlock()
+
+	// Parse outside the lock; concurrent misses for the same key parse twice and
+	// store equal modules.
+	mod, err := ParseModuleWithOptions(filename, source, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(el)
+	} else {
+		c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, mod: mod})
+		for c.lru.Len() > c.maxEntries {
+			oldest := c.lru.Back()
+			c.lru.Remove(oldest)
+			delete(c.entries, oldest.Value.(*cacheEntry).key)
+			c.stats.Evictions++
+		}
+	}
+	return mod.Copy(), nil
+}
+
+// Stats returns a snapshot of the cache statistics.
+func (c *Cache) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := c.stats
+	stats.Entries = c.lru.Len()
+	return stats
+}
+
+func newCacheKey(filename, source string, opts ParseOptions) cacheKey {
+	features := slices.Clone(opts.Features)
+	slices.Sort(features)
+	features = slices.Compact(features)
+	return cacheKey{
+		filename:      filename,
+		sourceHash:    sha256.Sum256([]byte(source)),
+		imports:       strings.Join(opts.Imports, "\x00"),
+		features:      strings.Join(features, "\x00"),
+		regoVersion:   opts.RegoVersion,
+		forbidMarkers: opts.ForbidMarkers,
+		bigLiterals:   opts.BigDecimalLiterals,
+	}
+}
@@ -0,0 +1,115 @@
+package module
+
+import (
+	"sync"
+	"testing"
+)
+
+const cacheTestSource = `package test
+import data.regobrick.default_false
+
+allow if input.x
+`
+
+func TestCache_HitsReturnDeepCopies(t *testing.T) {
+	c := NewCache(10)
+
+	first, err := c.ParseModuleWithOptions("test.rego", cacheTestSource, ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseModuleWithOptions error: %v", err)
+	}
+	// Mutating a returned module must not affect later results.
+	first.Rules = first.Rules[:1]
+
+	second, err := c.ParseModuleWithOptions("test.rego", cacheTestSource, ParseOptions{})
+	if err != nil {
+		t.Fatalf("ParseModuleWithOptions error: %v", err)
+	}
+	if len(second.Rules) != 2 {
+		t.Fatalf("expected the cached module to be unaffected, got %d rules", len(second.Rules))
+	}
+	if first.Rules[0] == second.Rules[0] {
+		t.Error("expected distinct copies of the rules")
+	}
+
+	if got := c.Stats(); got.Hits != 1 || got.Misses != 1 || got.Entries != 1 {
+		t.Errorf("unexpected stats: %+v", got)
+	}
+}
+
+func TestCache_Key(t *testing.T) {
+	c := NewCache(10)
+	parse := func(filename, source string, opts ParseOptions) {
+		t.Helper()
+		if _, err := c.ParseModuleWithOptions(filename, source, opts); err != nil {
+			t.Fatalf("ParseModuleWithOptions error: %v", err)
+		}
+	}
+
+	parse("a.rego", "package a\n", ParseOptions{})
+	parse("b.rego", "package a\n", ParseOptions{})                              // filename
+	parse("a.rego", "package a\n\nx := 1\n", ParseOptions{})                    // source
+	parse("a.rego", "package a\n", ParseOptions{Imports: []string{"data.lib"}}) // imports
+	parse("a.rego", "package a\n", ParseOptions{Features: []string{"default_false", "default_empty"}})
+	parse("a.rego", "package a\n", ParseOptions{Features: []string{"default_empty", "default_false"}}) // same feature set
+	parse("a.rego", "package a\n", ParseOptions{BigDecimalLiterals: true})                             // literal limits
+	if got := c.Stats(); got.Misses != 6 || got.Hits != 1 {
+		t.Errorf("expected 6 misses and 1 hit, got %+v", got)
+	}
+}
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewCache(2)
+	for _, name := range []string{"a.rego", "b.rego", "a.rego", "c.rego", "a.rego", "b.rego"} {
+		if _, err := c.ParseModuleWithOptions(name, "package p\n", ParseOptions{}); err != nil {
+			t.Fatalf("ParseModuleWithOptions error: %v", err)
+		}
+	}
+	// a, b miss; a hits; c misses and evicts b; a hits; b misses and evicts c.
+	got := c.Stats()
+	if got.Hits != 2 || got.Misses != 4 || got.Evictions != 2 || got.Entries != 2 {
+		t.Errorf("unexpected stats: %+v", got)
+	}
+}
+
+func TestCache_ErrorsNotCached(t *testing.T) {
+	c := NewCache(2)
+	for i := 0; i < 2; i++ {
+		if _, err := c.ParseModuleWithOptions("bad.rego", "package", ParseOptions{}); err == nil {
+			t.Fatal("expected error, got nil")
+		}
+	}
+	if got := c.Stats(); got.Entries != 0 || got.Misses != 2 {
+		t.Errorf("unexpected stats: %+v", got)
+	}
+}
+
+func TestCache_Concurrent(t *testing.T) {
+	c := NewCache(4)
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			mod, err := c.ParseModuleWithOptions("test.rego", cacheTestSource, ParseOptions{})
+			if err != nil {
+				t.Errorf("ParseModuleWithOptions error: %v", err)
+				return
+			}
+			mod.Rules = nil
+		}()
+	}
+	wg.Wait()
+	if got := c.Stats(); got.Hits+got.Misses != 16 || got.Entries != 1 {
+		t.Errorf("unexpected stats: %+v", got)
+	}
+}
+
+func TestNewCache_InvalidSizePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic, got none")
+		}
+	}()
+	NewCache(0)
+}
@@ -0,0 +1,281 @@
+package module
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+)
+
+// feature is a module transform enabled by "import data.regobrick.<name>".
+type feature struct {
+	name string
+	fn   func(*ast.Module) error
+	// withOptions, if set, replaces fn for built-in features whose transform
+	// depends on the ParseOptions of the module, e.g. decimal_literals.
+	withOptions func(*ast.Module, ParseOptions) error
+	// builtin marks the features shipped with regobrick. Their errors already
+	// carry a "regobrick: <feature>:" prefix and are returned unwrapped.
+	builtin  bool
+	after    []string
+	before   []string
+	requires []string
+}
+
+// featureConfig collects the FeatureOption values passed to RegisterFeature.
+type featureConfig struct {
+	after    []string
+	before   []string
+	requires []string
+}
+
+// FeatureOption configures how a feature registered with RegisterFeature is
+// ordered relative to, or depends on, other features. It is exposed publicly as
+// regobrick.FeatureOption.
+type FeatureOption func(*featureConfig)
+
+// After orders the feature after the named features when they are enabled in
+// the same module. Naming a feature that is not enabled (or not registered) has
+// no effect.
+func After(names ...string) FeatureOption {
+	return func(cfg *featureConfig) {
+		cfg.after = append(cfg.after, names...)
+	}
+}
+
+// Before orders the feature before the named features when they are enabled in
+// the same module. Naming a feature that is not enabled (or not registered) has
+// no effect.
+func Before(names ...string) FeatureOption {
+	return func(cfg *featureConfig) {
+		cfg.before = append(cfg.before, names...)
+	}
+}
+
+// Requires enables the named features whenever the feature is enabled, and
+// orders the feature after them. ParseModule returns an error if a required
+// feature is not registered.
+func Requires(names ...string) FeatureOption {
+	return func(cfg *featureConfig) {
+		cfg.requires = append(cfg.requires, names...)
+	}
+}
+
+// featureNamePattern restricts feature names to Rego identifiers so that every
+// feature can be enabled with a plain "import data.regobrick.<name>".
+var featureNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+var (
+	featuresMu sync.RWMutex
+	features   = map[string]*feature{}
+)
+
+func init() {
+	registerBuiltinFeature(featureDefaultValue, addDefaultValue)
+	// default_value runs first so that an explicitly annotated default wins over
+	// the defaults synthesized by default_false and default_empty.
+	registerBuiltinFeature(featureDefaultFalse, addDefaultFalse, After(featureDefaultValue))
+	registerBuiltinFeature(featureDefaultFalseFunctions, addDefaultFalseFunctions, After(featureDefaultValue))
+	registerBuiltinFeature(featureDefaultEmpty, func(mod *ast.Module) error {
+		addDefaultEmpty(mod)
+		return nil
+	}, After(featureDefaultValue))
+	// decimal_literals also checks the values of synthesized defaults.
+	registerBuiltinFeatureWithOptions(featureDecimalLiterals, checkDecimalLiterals, After(featureDefaultValue))
+	registerBuiltinFeature(featureCollectReasons, collectReasons)
+	registerBuiltinFeature(featureDecimalArithmetic, rewriteDecimalOperators)
+}
+
+func registerBuiltinFeature(name string, fn func(*ast.Module) error, opts ...FeatureOption) {
+	f := newFeature(name, fn, opts)
+	f.builtin = true
+	storeFeature(f)
+}
+
+func registerBuiltinFeatureWithOptions(name string, fn func(*ast.Module, ParseOptions) error, opts ...FeatureOption) {
+	f := newFeature(name, nil, opts)
+	f.withOptions = fn
+	f.builtin = true
+	storeFeature(f)
+}
+
+// RegisterFeature registers fn as the transform for "import data.regobrick.<name>".
+// Registered features get the same treatment as the built-in ones: the marker
+// import is validated and stripped, and a transform error is returned by
+// ParseModule (and therefore panics in Module/Modules under the fail-fast
+// contract).
+//
+// RegisterFeature panics if name is not a Rego identifier, fn is nil, or a
+// feature with the same name (including a built-in one) is already registered.
+// Must be called during package initialization (init function).
+func RegisterFeature(name string, fn func(*ast.Module) error, opts ...FeatureOption) {
+	if !featureNamePattern.MatchString(name) {
+		panic(fmt.Sprintf("regobrick: invalid feature name %q: must be a Rego identifier", name))
+	}
+	if fn == nil {
+		panic(fmt.Sprintf("regobrick: feature %q has a nil transform", name))
+	}
+	storeFeature(newFeature(name, fn, opts))
+}
+
+func newFeature(name string, fn func(*ast.Module) error, opts []FeatureOption) *feature {
+	cfg := featureConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &feature{
+		name:     name,
+		fn:       fn,
+		after:    cfg.after,
+		before:   cfg.before,
+		requires: cfg.requires,
+	}
+}
+
+func storeFeature(f *feature) {
+	featuresMu.Lock()
+	defer featuresMu.Unlock()
+	if _, dup := features[f.name]; dup {
+		panic(fmt.Sprintf("regobrick: feature %q is already registered", f.name))
+	}
+	features[f.name] = f
+}
+
+func isKnownFeature(name string) bool {
+	featuresMu.RLock()
+	defer featuresMu.RUnlock()
+	_, ok := features[name]
+	return ok
+}
+
+// knownFeatureNames returns the names of every registered feature, sorted.
+func knownFeatureNames() []string {
+	featuresMu.RLock()
+	defer featuresMu.RUnlock()
+	names := make([]string, 0, len(features))
+	for name := range features {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolveFeatures expands the required features of names and returns every
+// feature to apply, in a deterministic order: each feature runs after the
+// features it requires or is ordered After, and before the features it is
+// ordered Before. Features without an ordering constraint between them run in
+// name order. It returns an error for an unknown required feature or an
+// ordering cycle.
+func resolveFeatures(names []string) ([]*feature, error) {
+	featuresMu.RLock()
+	defer featuresMu.RUnlock()
+
+	// Expand required features transitively.
+	enabled := make(map[string]*feature)
+	queue := append([]string(nil), names...)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if _, seen := enabled[name]; seen {
+			continue
+		}
+		f, ok := features[name]
+		if !ok {
+			return nil, fmt.Errorf("regobrick: unknown feature %q", name)
+		}
+		enabled[name] = f
+		for _, req := range f.requires {
+			if _, ok := features[req]; !ok {
+				return nil, fmt.Errorf("regobrick: feature %q requires unknown feature %q", name, req)
+			}
+			queue = append(queue, req)
+		}
+	}
+
+	// Build the ordering graph among the enabled features: edge a -> b means a
+	// runs before b.
+	succ := make(map[string][]string)
+	indegree := make(map[string]int, len(enabled))
+	addEdge := func(from, to string) {
+		if _, ok := enabled[from]; !ok {
+			return
+		}
+		if _, ok := enabled[to]; !ok {
+			return
+		}
+		if slices.Contains(succ[from], to) {
+			return
+		}
+		succ[from] = append(succ[from], to)
+		indegree[to]++
+	}
+	for name, f := range enabled {
+		for _, dep := range f.requires {
+			addEdge(dep, name)
+		}
+		for _, dep := range f.after {
+			addEdge(dep, name)
+		}
+		for _, next := range f.before {
+			addEdge(name, next)
+		}
+	}
+
+	// Kahn's algorithm, always picking the smallest ready name so the result does
+	// not depend on map iteration or registration order.
+	var ready []string
+	for name := range enabled {
+		if indegree[name] == 0 {
+			ready = append(ready, name)
+		}
+	}
+	ordered := make([]*feature, 0, len(enabled))
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		name := ready[0]
+		ready = ready[1:]
+		ordered = append(ordered, enabled[name])
+		for _, next := range succ[name] {
+			indegree[next]--
+			if indegree[next] == 0 {
+				ready = append(ready, next)
+			}
+		}
+	}
+	if len(ordered) != len(enabled) {
+		var cycle []string
+		for name := range enabled {
+			if indegree[name] > 0 {
+				cycle = append(cycle, name)
+			}
+		}
+		sort.Strings(cycle)
+		return nil, fmt.Errorf("regobrick: feature ordering cycle among %s", strings.Join(cycle, ", "))
+	}
+	return ordered, nil
+}
+
+// apply runs the feature's transform on mod, parsed with opts. A panic in a
+// registered transform is returned as an error so that ParseModule keeps its
+// never-panics contract.
+func (f *feature) apply(mod *ast.Module, opts ParseOptions) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("regobrick: feature %q panicked: %v", f.name, rec)
+		}
+	}()
+	if f.withOptions != nil {
+		return f.withOptions(mod, opts)
+	}
+	if err := f.fn(mod); err != nil {
+		if f.builtin {
+			return err
+		}
+		return fmt.Errorf("regobrick: feature %q: %w", f.name, err)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package module
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+)
+
+// Test features are registered once per test binary: the registry has no
+// unregister, so registering from a test function would panic under -count=N.
+func init() {
+	RegisterFeature("test_append_allow", func(mod *ast.Module) error {
+		mod.Rules = append(mod.Rules, ast.MustParseRule(`allow if input.extra`))
+		return nil
+	}, Before(featureDefaultFalse))
+	RegisterFeature("test_fail", func(*ast.Module) error {
+		return errors.New("boom")
+	})
+	RegisterFeature("test_panic", func(*ast.Module) error {
+		panic("kaboom")
+	})
+	RegisterFeature("test_requires_false", func(*ast.Module) error { return nil },
+		Requires(featureDefaultFalse))
+	RegisterFeature("test_requires_missing", func(*ast.Module) error { return nil },
+		Requires("not_registered"))
+	RegisterFeature("test_cycle_a", func(*ast.Module) error { return nil }, After("test_cycle_b"))
+	RegisterFeature("test_cycle_b", func(*ast.Module) error { return nil }, After("test_cycle_a"))
+	RegisterFeature("test_order_z", func(*ast.Module) error { return nil })
+	RegisterFeature("test_order_m", func(*ast.Module) error { return nil }, After("test_order_z"))
+}
+
+func featureNames(fs []*feature) []string {
+	names := make([]string, len(fs))
+	for i, f := range fs {
+		names[i] = f.name
+	}
+	return names
+}
+
+func TestRegisterFeature_AppliedAndMarkerStripped(t *testing.T) {
+	source := `package test
+import data.regobrick.test_append_allow
+
+deny if { input.blocked }
+`
+	mod, err := ParseModule("test.rego", source, nil)
+	if err != nil {
+		t.Fatalf("ParseModule error: %v", err)
+	}
+	found := false
+	for _, r := range mod.Rules {
+		if !r.Default && r.Head.Ref().String() == "allow" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected the registered transform to append 'allow'")
+	}
+	if len(mod.Imports) != 0 {
+		t.Errorf("expected marker import to be stripped, got %v", mod.Imports)
+	}
+}
+
+func TestRegisterFeature_OrderedBeforeDefaultFalse(t *testing.T) {
+	// The imports are deliberately listed in the "wrong" order: Before() must
+	// still run the custom feature first so its rule gets a default.
+	source := `package test
+import data.regobrick.default_false
+import data.regobrick.test_append_allow
+`
+	mod, err := ParseModule("test.rego", source, nil)
+	if err != nil {
+		t.Fatalf("ParseModule error: %v", err)
+	}
+	hasDefault := false
+	for _, r := range mod.Rules {
+		if r.Default && r.Head.Ref().String() == "allow" {
+			hasDefault = true
+		}
+	}
+	if !hasDefault {
+		t.Error("expected default for 'allow' appended by a feature ordered before default_false")
+	}
+}
+
+func TestRegisterFeature_ErrorAndPanic(t *testing.T) {
+	for _, name := range []string{"test_fail", "test_panic"} {
+		source := "package test\nimport data.regobrick." + name + "\n"
+		_, err := ParseModule("test.rego", source, nil)
+		if err == nil {
+			t.Errorf("%s: expected error, got nil", name)
+			continue
+		}
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("%s: error should name the feature, got: %v", name, err)
+		}
+	}
+}
+
+func TestRegisterFeature_Requires(t *testing.T) {
+	source := `package test
+import data.regobrick.test_requires_false
+
+allow if { input.x }
+`
+	mod, err := ParseModule("test.rego", source, nil)
+	if err != nil {
+		t.Fatalf("ParseModule error: %v", err)
+	}
+	hasDefault := false
+	for _, r := range mod.Rules {
+		if r.Default && r.Head.Ref().String() == "allow" {
+			hasDefault = true
+		}
+	}
+	if !hasDefault {
+		t.Error("expected required default_false feature to be applied")
+	}
+
+	_, err = ParseModule("test.rego", "package test\nimport data.regobrick.test_requires_missing\n", nil)
+	if err == nil || !strings.Contains(err.Error(), "not_registered") {
+		t.Errorf("expected error naming the missing required feature, got: %v", err)
+	}
+}
+
+func TestResolveFeatures_DeterministicOrder(t *testing.T) {
+	got, err := resolveFeatures([]string{featureDefaultEmpty, "test_order_m", featureDefaultFalse, featureDefaultValue, "test_order_z"})
+	if err != nil {
+		t.Fatalf("resolveFeatures error: %v", err)
+	}
+	want := []string{featureDefaultValue, featureDefaultEmpty, featureDefaultFalse, "test_order_z", "test_order_m"}
+	if names := featureNames(got); strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected order %v, got %v", want, names)
+	}
+}
+
+func TestResolveFeatures_Cycle(t *testing.T) {
+	_, err := resolveFeatures([]string{"test_cycle_a", "test_cycle_b"})
+	if err == nil {
+		t.Fatal("expected cycle error, got nil")
+	}
+	if !strings.Contains(err.Error(), "test_cycle_a") || !strings.Contains(err.Error(), "test_cycle_b") {
+		t.Errorf("error should name the features in the cycle, got: %v", err)
+	}
+}
+
+func TestRegisterFeature_InvalidRegistrationPanics(t *testing.T) {
+	tests := []struct {
+		name    string
+		feature string
+		fn      func(*ast.Module) error
+	}{
+		{"duplicate_builtin", featureDefaultFalse, func(*ast.Module) error { return nil }},
+		{"invalid_name", "bad.name", func(*ast.Module) error { return nil }},
+		{"empty_name", "", func(*ast.Module) error { return nil }},
+		{"nil_fn", "test_nil_fn", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Fatal("expected panic, got none")
+				}
+			}()
+			RegisterFeature(tt.feature, tt.fn)
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package module
+
+import (
+	"fmt"
+
+	"github.com/open-policy-agent/opa/v1/format"
+)
+
+// FormatModule runs ParseModule on the given source and imports and renders the
+// transformed module as Rego source through OPA's formatter. The output is the
+// effective policy: it includes the default rules synthesized by the enabled
+// features and the injected imports, and no longer contains the
+// "data.regobrick.*" marker imports.
+func FormatModule(filename, source string, imports []string) ([]byte, error) {
+	return FormatModuleWithOptions(filename, source, ParseOptions{Imports: imports})
+}
+
+// FormatModuleWithOptions behaves like FormatModule, with the imports and the
+// Rego version taken from opts. The output uses the syntax of that version.
+func FormatModuleWithOptions(filename, source string, opts ParseOptions) ([]byte, error) {
+	mod, err := ParseModuleWithOptions(filename, source, opts)
+	if err != nil {
+		return nil, err
+	}
+	out, err := format.AstWithOpts(mod, format.Opts{RegoVersion: mod.RegoVersion()})
+	if err != nil {
+		return nil, fmt.Errorf("regobrick: cannot format module %q: %w", filename, err)
+	}
+	return out, nil
+}
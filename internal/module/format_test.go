@@ -0,0 +1,116 @@
+package module
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+)
+
+func TestFormatModule_IncludesTransforms(t *testing.T) {
+	source := `package test
+import data.regobrick.default_false
+
+# allow admins only
+allow if { helper.is_admin(input.user) }
+`
+	out, err := FormatModule("test.rego", source, []string{"data.helper"})
+	if err != nil {
+		t.Fatalf("FormatModule error: %v", err)
+	}
+	got := string(out)
+
+	if strings.Contains(got, "data.regobrick") {
+		t.Errorf("expected marker import to be dropped, got:\n%s", got)
+	}
+	if !strings.Contains(got, "import data.helper") {
+		t.Errorf("expected injected import, got:\n%s", got)
+	}
+	if !regexp.MustCompile(`(?m)^default allow :?= false$`).MatchString(got) {
+		t.Errorf("expected synthesized default rule, got:\n%s", got)
+	}
+	if !strings.Contains(got, "# allow admins only") {
+		t.Errorf("expected comments to be preserved, got:\n%s", got)
+	}
+
+	// The rendered policy must be valid Rego with the same rules.
+	mod, err := ast.ParseModule("rendered.rego", got)
+	if err != nil {
+		t.Fatalf("rendered output does not parse: %v\n%s", err, got)
+	}
+	if len(mod.Rules) != 2 {
+		t.Errorf("expected 2 rules in rendered output, got %d", len(mod.Rules))
+	}
+}
+
+func TestFormatModule_ParseError(t *testing.T) {
+	if _, err := FormatModule("bad.rego", "package", nil); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
+
+func TestFormatModuleWithOptions_V0(t *testing.T) {
+	source := `package test
+import data.regobrick.default_false
+
+allow { input.x }
+`
+	out, err := FormatModuleWithOptions("test.rego", source, ParseOptions{RegoVersion: ast.RegoV0})
+	if err != nil {
+		t.Fatalf("FormatModuleWithOptions error: %v", err)
+	}
+	got := string(out)
+	if !regexp.MustCompile(`(?m)^default allow = false$`).MatchString(got) {
+		t.Errorf("expected v0 default rule, got:\n%s", got)
+	}
+	if _, err := ast.ParseModuleWithOpts("rendered.rego", got, ast.ParserOptions{RegoVersion: ast.RegoV0}); err != nil {
+		t.Fatalf("rendered output is not valid v0 Rego: %v\n%s", err, got)
+	}
+}
+
+func TestFormatModule_StringDefaultValue(t *testing.T) {
+	source := `package test
+import data.regobrick.default_value
+
+# METADATA
+# custom:
+#   default: "none"
+tier := "gold" if input.level == "vip"
+
+# METADATA
+# custom:
+#   default: {"plan": "free", "seats": ["a"]}
+account := {"plan": "pro"} if input.paid
+`
+	out, err := FormatModule("test.rego", source, nil)
+	if err != nil {
+		t.Fatalf("FormatModule error: %v", err)
+	}
+	got := string(out)
+	if !regexp.MustCompile(`(?m)^default tier := "none"$`).MatchString(got) {
+		t.Errorf(`expected default tier := "none", got:\n%s`, got)
+	}
+
+	mod, err := ast.ParseModule("rendered.rego", got)
+	if err != nil {
+		t.Fatalf("rendered output does not parse: %v\n%s", err, got)
+	}
+	want := map[string]*ast.Term{
+		"tier":    ast.StringTerm("none"),
+		"account": ast.MustParseTerm(`{"plan": "free", "seats": ["a"]}`),
+	}
+	for _, r := range mod.Rules {
+		if !r.Default {
+			continue
+		}
+		ref := r.Head.Ref().String()
+		if !r.Head.Value.Equal(want[ref]) {
+			t.Errorf("rendered default for %q: expected %v, got %v", ref, want[ref], r.Head.Value)
+		}
+		delete(want, ref)
+	}
+	if len(want) != 0 {
+		t.Errorf("missing rendered defaults for %v:\n%s", want, got)
+	}
+}
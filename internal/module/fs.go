@@ -0,0 +1,261 @@
+package module
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/open-policy-agent/opa/v1/rego"
+)
+
+// ImportRule selects the imports to inject into the modules loaded from an fs.FS.
+// A rule applies to a module when every non-empty pattern matches; a rule with no
+// pattern applies to every module. It is exposed publicly as regobrick.ImportRule.
+//
+// Patterns are globs in the syntax of path.Match, applied per segment: "*" matches
+// within a single segment, and a "**" segment matches any number of segments
+// (including none). Package segments are separated by "." and file path segments
+// by "/".
+type ImportRule struct {
+	// Package matches the module's package path without the "data." prefix,
+	// e.g. "app.billing.*" or "app.**".
+	Package string
+	// Path matches the module's slash-separated path within the fs.FS,
+	// e.g. "billing/*.rego" or "**/money_*.rego".
+	Path string
+	// Imports lists the import paths to inject, as in ModuleOption.Imports.
+	Imports []string
+}
+
+// ImportsForPackage returns an ImportRule injecting imports into every module
+// whose package matches pattern.
+func ImportsForPackage(pattern string, imports ...string) ImportRule {
+	return ImportRule{Package: pattern, Imports: imports}
+}
+
+// ImportsForPath returns an ImportRule injecting imports into every module whose
+// path within the fs.FS matches pattern.
+func ImportsForPath(pattern string, imports ...string) ImportRule {
+	return ImportRule{Path: pattern, Imports: imports}
+}
+
+// FSOptions configures ModulesFSWithOptions and ModuleSet.AddFSWithOptions. It
+// is exposed publicly as regobrick.FSOptions.
+type FSOptions struct {
+	// Pattern selects the ".rego" files of the fs.FS to load ("" or "**" for all
+	// files).
+	Pattern string
+	// Rules selects the imports of each module by package or path glob.
+	Rules []ImportRule
+	// RegoVersion selects the Rego syntax of every module, e.g. ast.RegoV0. It
+	// also applies when reading the package matched by ImportRule.Package.
+	RegoVersion ast.RegoVersion
+	// Features lists features to apply to every module; see ParseOptions.
+	Features []string
+	// ForbidMarkers rejects modules containing "data.regobrick.*" marker
+	// imports; see ParseOptions.
+	ForbidMarkers bool
+	// BigDecimalLiterals checks number literals against BigDecimalBackend; see
+	// ParseOptions.
+	BigDecimalLiterals bool
+}
+
+// ModulesFS returns a rego.Rego option that adds every ".rego" file of fsys whose
+// path matches pattern ("" or "**" for all files), with the imports selected by
+// rules. Each module follows the fail-fast contract of Module; in addition,
+// ModulesFS panics if fsys cannot be walked, pattern or a rule is malformed, or no
+// file matches.
+func ModulesFS(fsys fs.FS, pattern string, rules ...ImportRule) func(*rego.Rego) {
+	return ModulesFSWithOptions(fsys, FSOptions{Pattern: pattern, Rules: rules})
+}
+
+// ModulesFSWithOptions behaves like ModulesFS, with the Rego version and the
+// features of the modules taken from opts.
+func ModulesFSWithOptions(fsys fs.FS, opts FSOptions) func(*rego.Rego) {
+	return func(r *rego.Rego) {
+		modules, err := loadFS(fsys, opts)
+		if err != nil {
+			panic(err.Error())
+		}
+		Modules(modules...)(r)
+	}
+}
+
+// AddFS adds every ".rego" file of fsys whose path matches pattern, with the
+// imports selected by rules, like ModulesFS. It returns an error if fsys cannot be
+// walked, pattern or a rule is malformed, or no file matches; the modules
+// themselves are only parsed by Options.
+func (s *ModuleSet) AddFS(fsys fs.FS, pattern string, rules ...ImportRule) error {
+	return s.AddFSWithOptions(fsys, FSOptions{Pattern: pattern, Rules: rules})
+}
+
+// AddFSWithOptions behaves like AddFS, with the Rego version and the features of
+// the modules taken from opts.
+func (s *ModuleSet) AddFSWithOptions(fsys fs.FS, opts FSOptions) error {
+	modules, err := loadFS(fsys, opts)
+	if err != nil {
+		return err
+	}
+	s.modules = append(s.modules, modules...)
+	return nil
+}
+
+// loadFS reads the ".rego" files of fsys matching opts.Pattern, in lexical
+// order, and resolves the imports of each one from opts.Rules.
+func loadFS(fsys fs.FS, opts FSOptions) ([]ModuleOption, error) {
+	pattern, rules := opts.Pattern, opts.Rules
+	if err := validateGlob(pattern, "/"); err != nil {
+		return nil, fmt.Errorf("regobrick: invalid file pattern %q: %w", pattern, err)
+	}
+	for _, rule := range rules {
+		if err := validateGlob(rule.Package, "."); err != nil {
+			return nil, fmt.Errorf("regobrick: invalid package pattern %q: %w", rule.Package, err)
+		}
+		if err := validateGlob(rule.Path, "/"); err != nil {
+			return nil, fmt.Errorf("regobrick: invalid path pattern %q: %w", rule.Path, err)
+		}
+	}
+
+	var modules []ModuleOption
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(name, ".rego") {
+			return nil
+		}
+		if pattern != "" && !matchGlob(pattern, name, "/") {
+			return nil
+		}
+		src, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		source := string(src)
+		modules = append(modules, ModuleOption{
+			Filename:           name,
+			Source:             source,
+			Imports:            importsFor(name, source, opts.RegoVersion, rules),
+			RegoVersion:        opts.RegoVersion,
+			Features:           opts.Features,
+			ForbidMarkers:      opts.ForbidMarkers,
+			BigDecimalLiterals: opts.BigDecimalLiterals,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("regobrick: cannot load modules: %w", err)
+	}
+	if len(modules) == 0 {
+		return nil, fmt.Errorf("regobrick: no .rego files match %q", pattern)
+	}
+	return modules, nil
+}
+
+// importsFor returns the imports of every rule matching the module, in rule
+// order. A module whose package cannot be determined (e.g. because it does not
+// parse under version) is only matched by rules without a Package pattern;
+// ParseModule reports its error later.
+func importsFor(filename, source string, version ast.RegoVersion, rules []ImportRule) []string {
+	return matchImportRules(filename, func() string { return packagePath(filename, source, version) }, rules)
+}
+
+// matchImportRules returns the imports of every rule matching the module at
+// filename, in rule order. pkg returns the module's package as formatted by
+// packageName, or "" if it is unknown; it is only called when a rule has a
+// Package pattern.
+func matchImportRules(filename string, pkg func() string, rules []ImportRule) []string {
+	var name string
+	nameKnown := false
+	var imports []string
+	for _, rule := range rules {
+		if rule.Path != "" && !matchGlob(rule.Path, filename, "/") {
+			continue
+		}
+		if rule.Package != "" {
+			if !nameKnown {
+				name, nameKnown = pkg(), true
+			}
+			if name == "" || !matchGlob(rule.Package, name, ".") {
+				continue
+			}
+		}
+		imports = append(imports, rule.Imports...)
+	}
+	return imports
+}
+
+// packagePath returns the package of source without the "data." prefix, e.g.
+// "app.billing", or "" if the module does not parse. The source is parsed with
+// the same Rego version as ParseModuleWithOptions will use, so a v0 module
+// loaded with ast.RegoV0 is matched by its package.
+func packagePath(filename, source string, version ast.RegoVersion) string {
+	mod, err := ast.ParseModuleWithOpts(filename, source, ast.ParserOptions{RegoVersion: version})
+	if err != nil || mod == nil {
+		return ""
+	}
+	return packageName(mod)
+}
+
+// packageName returns the package of mod without the "data." prefix, e.g.
+// "app.billing", or "" for a nil module.
+func packageName(mod *ast.Module) string {
+	if mod == nil || mod.Package == nil {
+		return ""
+	}
+	segments := make([]string, 0, len(mod.Package.Path)-1)
+	for _, term := range mod.Package.Path[1:] {
+		if s, ok := term.Value.(ast.String); ok {
+			segments = append(segments, string(s))
+		} else {
+			segments = append(segments, term.String())
+		}
+	}
+	return strings.Join(segments, ".")
+}
+
+// validateGlob reports a malformed segment of pattern.
+func validateGlob(pattern, sep string) error {
+	if pattern == "" {
+		return nil
+	}
+	for _, seg := range strings.Split(pattern, sep) {
+		if seg == "**" {
+			continue
+		}
+		if _, err := path.Match(seg, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchGlob reports whether name matches pattern, both split on sep. A "**"
+// pattern segment matches any number of name segments; any other segment is
+// matched with path.Match. pattern must have been checked with validateGlob.
+func matchGlob(pattern, name, sep string) bool {
+	return matchSegments(strings.Split(pattern, sep), strings.Split(name, sep))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,142 @@
+package module
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/open-policy-agent/opa/v1/rego"
+)
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"billing/invoice.rego":    {Data: []byte("package app.billing.invoice\nallow if input.x\n")},
+		"billing/refund/v2.rego":  {Data: []byte("package app.billing.refund.v2\nallow if input.x\n")},
+		"orders/create.rego":      {Data: []byte("package app.orders\nallow if input.x\n")},
+		"lib/money.rego":          {Data: []byte("package lib.money\nadd(a, b) := a + b\n")},
+		"README.md":               {Data: []byte("not rego")},
+		"broken/v0_syntax.rego":   {Data: []byte("package app.billing.old\nallow = true { input.x }\n")},
+		"billing/invoice_test.go": {Data: []byte("package x")},
+	}
+}
+
+func TestLoadFS_PackageAndPathRules(t *testing.T) {
+	opts, err := loadFS(testFS(), FSOptions{Rules: []ImportRule{
+		ImportsForPackage("app.billing.*", "data.lib.money"),
+		ImportsForPackage("app.**", "data.lib.common"),
+		ImportsForPath("orders/*.rego", "data.lib.orders"),
+	}})
+	if err != nil {
+		t.Fatalf("loadFS error: %v", err)
+	}
+
+	got := map[string]string{}
+	for _, opt := range opts {
+		got[opt.Filename] = strings.Join(opt.Imports, ",")
+	}
+	want := map[string]string{
+		"billing/invoice.rego":   "data.lib.money,data.lib.common",
+		"billing/refund/v2.rego": "data.lib.common",
+		"orders/create.rego":     "data.lib.common,data.lib.orders",
+		"lib/money.rego":         "",
+		// The package of a module that does not parse is unknown, so only
+		// path rules apply to it.
+		"broken/v0_syntax.rego": "",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d modules, got %v", len(want), got)
+	}
+	for name, imports := range want {
+		if got[name] != imports {
+			t.Errorf("%s: expected imports %q, got %q", name, imports, got[name])
+		}
+	}
+}
+
+func TestLoadFS_Pattern(t *testing.T) {
+	opts, err := loadFS(testFS(), FSOptions{Pattern: "billing/**"})
+	if err != nil {
+		t.Fatalf("loadFS error: %v", err)
+	}
+	var names []string
+	for _, opt := range opts {
+		names = append(names, opt.Filename)
+	}
+	if got := strings.Join(names, ","); got != "billing/invoice.rego,billing/refund/v2.rego" {
+		t.Errorf("unexpected files: %s", got)
+	}
+
+	if _, err := loadFS(testFS(), FSOptions{Pattern: "nothing/*.rego"}); err == nil || !strings.Contains(err.Error(), "no .rego files") {
+		t.Errorf("expected no-match error, got: %v", err)
+	}
+	if _, err := loadFS(testFS(), FSOptions{Pattern: "["}); err == nil || !strings.Contains(err.Error(), "invalid file pattern") {
+		t.Errorf("expected invalid pattern error, got: %v", err)
+	}
+	if _, err := loadFS(testFS(), FSOptions{Rules: []ImportRule{ImportsForPackage("app.[", "data.x")}}); err == nil {
+		t.Error("expected invalid package pattern error, got nil")
+	}
+}
+
+// TestLoadFS_RegoV0 checks that the package of a v0 module is read with the
+// configured Rego version, so Package rules match it.
+func TestLoadFS_RegoV0(t *testing.T) {
+	fsys := fstest.MapFS{
+		"billing/old.rego": {Data: []byte("package app.billing.old\n\nallow { money.ok }\n")},
+		"lib/money.rego":   {Data: []byte("package lib.money\n\nok = true\n")},
+	}
+	rules := []ImportRule{ImportsForPackage("app.billing.**", "data.lib.money")}
+	opts, err := loadFS(fsys, FSOptions{Rules: rules, RegoVersion: ast.RegoV0, Features: []string{featureDefaultFalse}})
+	if err != nil {
+		t.Fatalf("loadFS error: %v", err)
+	}
+	if len(opts) != 2 || opts[0].Filename != "billing/old.rego" {
+		t.Fatalf("unexpected modules: %+v", opts)
+	}
+	if got := strings.Join(opts[0].Imports, ","); got != "data.lib.money" {
+		t.Errorf("expected the v0 module to get data.lib.money, got %q", got)
+	}
+	if opts[0].RegoVersion != ast.RegoV0 || !slices.Equal(opts[0].Features, []string{featureDefaultFalse}) {
+		t.Errorf("expected the options to carry the version and features, got %+v", opts[0])
+	}
+
+	set := NewModuleSet()
+	if err := set.AddFSWithOptions(fsys, FSOptions{Rules: rules, RegoVersion: ast.RegoV0}); err != nil {
+		t.Fatalf("AddFSWithOptions error: %v", err)
+	}
+	regoOpts, err := set.Options()
+	if err != nil {
+		t.Fatalf("Options error: %v", err)
+	}
+	rs, err := rego.New(append(regoOpts, rego.Query("data.app.billing.old.allow"))...).Eval(context.Background())
+	if err != nil {
+		t.Fatalf("Eval error: %v", err)
+	}
+	if len(rs) != 1 || rs[0].Expressions[0].Value != true {
+		t.Errorf("expected allow to be true, got %v", rs)
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name, sep string
+		want               bool
+	}{
+		{"app.billing.*", "app.billing.invoice", ".", true},
+		{"app.billing.*", "app.billing", ".", false},
+		{"app.billing.*", "app.billing.refund.v2", ".", false},
+		{"app.billing.**", "app.billing", ".", true},
+		{"app.billing.**", "app.billing.refund.v2", ".", true},
+		{"**.v2", "app.billing.refund.v2", ".", true},
+		{"*/*.rego", "billing/invoice.rego", "/", true},
+		{"*.rego", "billing/invoice.rego", "/", false},
+		{"**/*.rego", "invoice.rego", "/", true},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.name, tt.sep); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
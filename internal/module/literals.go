@@ -0,0 +1,51 @@
+package module
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/sky1core/regobrick/internal/decimal"
+)
+
+// featureDecimalLiterals is the regobrick feature that rejects number literals
+// the decimal operators cannot parse.
+const featureDecimalLiterals = "decimal_literals"
+
+// checkDecimalLiterals returns an error for every ast.Number literal of mod that
+// the decimal operators (UseDecimalArithmetic) cannot parse, e.g. "1e-25" (more
+// than 19 decimal places) or "1e100" (an exponent beyond decimal.MaxExpandedLen).
+// With opts.BigDecimalLiterals the limits of BigDecimalBackend apply instead, so
+// only exponents beyond decimal.MaxExponent are rejected. At evaluation time such
+// a literal makes its operation fail, which by default silently leaves the rule
+// undefined; this check moves the failure to parse time.
+//
+// Every offending literal is reported, joined with errors.Join, with the file and
+// line where it appears.
+func checkDecimalLiterals(mod *ast.Module, opts ParseOptions) error {
+	parse := func(s string) error {
+		_, err := decimal.Parse(s)
+		return err
+	}
+	if opts.BigDecimalLiterals {
+		parse = func(s string) error {
+			_, err := decimal.ParseRat(s)
+			return err
+		}
+	}
+	var errs []error
+	ast.WalkTerms(mod, func(t *ast.Term) bool {
+		n, ok := t.Value.(ast.Number)
+		if !ok {
+			return false
+		}
+		if err := parse(string(n)); err != nil {
+			errs = append(errs, fmt.Errorf(
+				"regobrick: %s: number %s at %v cannot be represented as a decimal: %v",
+				featureDecimalLiterals, n, t.Location, err,
+			))
+		}
+		return false
+	})
+	return errors.Join(errs...)
+}
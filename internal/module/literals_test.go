@@ -0,0 +1,84 @@
+package module
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckDecimalLiterals(t *testing.T) {
+	source := `package pricing
+import data.regobrick.decimal_literals
+
+fee := input.amount * 0.0025
+
+tiny := input.amount * 1e-25
+
+huge := 1e100
+
+ok := [1e-8, -2.5E+3, 123456789012345678901234567890]
+`
+	_, err := ParseModule("pricing.rego", source, nil)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	msg := err.Error()
+	for _, want := range []string{"1e-25 at pricing.rego:6", "1e100 at pricing.rego:8"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error should contain %q, got: %v", want, msg)
+		}
+	}
+	for _, unwanted := range []string{"0.0025", "1e-8", "2.5E+3", "123456789"} {
+		if strings.Contains(msg, unwanted) {
+			t.Errorf("error should not report %q, got: %v", unwanted, msg)
+		}
+	}
+}
+
+func TestCheckDecimalLiterals_ViaOptionAndDefaults(t *testing.T) {
+	source := `package pricing
+
+# METADATA
+# custom:
+#   default: 1e-30
+rate := input.rate if input.rate
+`
+	_, err := ParseModuleWithOptions("pricing.rego", source, ParseOptions{
+		Features: []string{featureDefaultValue, featureDecimalLiterals},
+	})
+	if err == nil || !strings.Contains(err.Error(), "pricing.rego:6") {
+		t.Fatalf("expected error for the synthesized default value, got: %v", err)
+	}
+
+	if _, err := ParseModuleWithOptions("pricing.rego", "package pricing\nfee := 0.25\n", ParseOptions{
+		Features: []string{featureDecimalLiterals},
+	}); err != nil {
+		t.Errorf("expected valid literals to pass, got: %v", err)
+	}
+}
+
+func TestCheckDecimalLiterals_BigDecimal(t *testing.T) {
+	source := `package pricing
+import data.regobrick.decimal_literals
+
+tiny := input.amount * 1e-25
+
+token := 1.000000000000000000000001
+
+huge := 1e1001
+`
+	_, err := ParseModuleWithOptions("pricing.rego", source, ParseOptions{BigDecimalLiterals: true})
+	if err == nil || !strings.Contains(err.Error(), "1e1001 at pricing.rego:8") {
+		t.Fatalf("expected error for 1e1001, got: %v", err)
+	}
+	for _, accepted := range []string{"1e-25", "1.000000000000000000000001"} {
+		if strings.Contains(err.Error(), accepted) {
+			t.Errorf("error should not report %q, got: %v", accepted, err)
+		}
+	}
+
+	// The same literals are rejected against the default udecimal limits.
+	_, err = ParseModule("pricing.rego", source, nil)
+	if err == nil || !strings.Contains(err.Error(), "1e-25") || !strings.Contains(err.Error(), "1.000000000000000000000001") {
+		t.Errorf("expected udecimal errors, got: %v", err)
+	}
+}
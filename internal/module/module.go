@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/open-policy-agent/opa/v1/ast"
 	"github.com/open-policy-agent/opa/v1/rego"
 )
 
@@ -15,8 +16,23 @@ type ModuleOption struct {
 	// Source is the raw Rego source code of the module.
 	Source string
 	// Imports lists additional import paths (e.g. "data.foo.bar") to inject into
-	// the module.
+	// the module. A path may carry an alias, e.g. "data.lib.v2.money as money2".
 	Imports []string
+	// RegoVersion selects the Rego syntax of Source, e.g. ast.RegoV0. The zero
+	// value means the v1 syntax; see ParseOptions.
+	RegoVersion ast.RegoVersion
+	// Features lists features to apply as if the source imported
+	// "data.regobrick.<feature>"; see ParseOptions.
+	Features []string
+	// ForbidMarkers rejects a source containing "data.regobrick.*" marker
+	// imports; see ParseOptions.
+	ForbidMarkers bool
+	// BigDecimalLiterals checks number literals against BigDecimalBackend; see
+	// ParseOptions.
+	BigDecimalLiterals bool
+	// Cache, if set, serves the transformed module from a Cache instead of
+	// parsing it again.
+	Cache *Cache
 }
 
 // Module returns a rego.Rego option that adds a single Rego module built from
@@ -34,28 +50,78 @@ type ModuleOption struct {
 // otherwise silently drop the requested behavior.
 func Module(filename, source string, imports []string) func(*rego.Rego) {
 	return func(r *rego.Rego) {
-		parsedModule, err := ParseModule(filename, source, imports)
+		opt, err := moduleOption(ModuleOption{Filename: filename, Source: source, Imports: imports}, true)
 		if err != nil {
-			// Nothing regobrick-specific was requested: preserve the historical
-			// fallback so plain modules (including v0 syntax) still compile.
-			if len(imports) == 0 && !strings.Contains(source, "data.regobrick.") {
-				rego.Module(filename, source)(r)
-				return
-			}
-			panic(fmt.Sprintf("regobrick: cannot process module %q: %v", filename, err))
+			panic(err.Error())
+		}
+		opt(r)
+	}
+}
+
+// moduleOption runs ParseModuleWithOptions on m and returns the rego.Rego option
+// that adds the result. With fallback, when nothing regobrick-specific was
+// requested (no imports, features or RegoVersion, and the source does not
+// reference a "data.regobrick." feature), a parse failure falls back to
+// rego.Module(m.Filename, m.Source) instead of an error. Any other failure is
+// returned as a *ModuleError.
+func moduleOption(m ModuleOption, fallback bool) (func(*rego.Rego), error) {
+	opts := ParseOptions{
+		Imports:            m.Imports,
+		RegoVersion:        m.RegoVersion,
+		Features:           m.Features,
+		ForbidMarkers:      m.ForbidMarkers,
+		BigDecimalLiterals: m.BigDecimalLiterals,
+	}
+	var parsedModule *ast.Module
+	var err error
+	if m.Cache != nil {
+		parsedModule, err = m.Cache.ParseModuleWithOptions(m.Filename, m.Source, opts)
+	} else {
+		parsedModule, err = ParseModuleWithOptions(m.Filename, m.Source, opts)
+	}
+	if err != nil {
+		// Nothing regobrick-specific was requested: preserve the historical
+		// fallback so plain modules (including v0 syntax) still compile.
+		if fallback && len(m.Imports) == 0 && len(m.Features) == 0 && m.RegoVersion == ast.RegoUndefined &&
+			!strings.Contains(m.Source, "data.regobrick.") {
+			return rego.Module(m.Filename, m.Source), nil
 		}
-		rego.ParsedModule(parsedModule)(r)
+		return nil, &ModuleError{Filename: m.Filename, Err: err}
 	}
+	return rego.ParsedModule(parsedModule), nil
 }
 
-// Modules adds multiple Rego modules to the Brick. It applies Module to each
-// option and follows the same fail-fast contract: a module that requested
-// regobrick behavior (imports or a "data.regobrick." feature) but failed to parse
-// causes a panic, while a fully plain module falls back to rego.Module.
+// ModuleError reports a module that could not be processed. Its message has the
+// same `regobrick: cannot process module "<filename>": <cause>` form as the
+// Module panic.
+type ModuleError struct {
+	// Filename is the name of the module that failed.
+	Filename string
+	// Err is the underlying ParseModule error.
+	Err error
+}
+
+func (e *ModuleError) Error() string {
+	return fmt.Sprintf("regobrick: cannot process module %q: %v", e.Filename, e.Err)
+}
+
+func (e *ModuleError) Unwrap() error {
+	return e.Err
+}
+
+// Modules adds multiple Rego modules to the Brick. It processes each option like
+// Module (honoring its RegoVersion) and follows the same fail-fast contract: a
+// module that requested regobrick behavior (imports, a RegoVersion, or a
+// "data.regobrick." feature) but failed to parse causes a panic, while a fully
+// plain module falls back to rego.Module.
 func Modules(moduleOpts ...ModuleOption) func(*rego.Rego) {
 	return func(r *rego.Rego) {
-		for _, opt := range moduleOpts {
-			Module(opt.Filename, opt.Source, opt.Imports)(r)
+		for _, m := range moduleOpts {
+			opt, err := moduleOption(m, true)
+			if err != nil {
+				panic(err.Error())
+			}
+			opt(r)
 		}
 	}
 }
@@ -0,0 +1,82 @@
+package module
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/open-policy-agent/opa/v1/rego"
+)
+
+// ModuleSet collects Rego modules and turns them into rego.Rego options without
+// panicking. It is the error-returning counterpart of Modules, meant for
+// long-running services where one bad policy must not crash the process. It is
+// exposed publicly as regobrick.ModuleSet.
+//
+// A ModuleSet is not safe for concurrent use.
+type ModuleSet struct {
+	modules []ModuleOption
+	cache   *Cache
+}
+
+// NewModuleSet returns an empty ModuleSet.
+func NewModuleSet() *ModuleSet {
+	return &ModuleSet{}
+}
+
+// Add records a module built from filename, source, and optional imports. The
+// module is only parsed by Options. Add returns the set to allow chaining.
+func (s *ModuleSet) Add(filename, source string, imports []string) *ModuleSet {
+	s.modules = append(s.modules, ModuleOption{Filename: filename, Source: source, Imports: imports})
+	return s
+}
+
+// AddModules records modules described by ModuleOption values, e.g. to set a
+// RegoVersion. It returns the set to allow chaining.
+func (s *ModuleSet) AddModules(opts ...ModuleOption) *ModuleSet {
+	s.modules = append(s.modules, opts...)
+	return s
+}
+
+// WithCache makes Options take transformed modules from cache, for every module
+// whose ModuleOption does not set its own Cache. It returns the set to allow
+// chaining.
+func (s *ModuleSet) WithCache(cache *Cache) *ModuleSet {
+	s.cache = cache
+	return s
+}
+
+// Options parses every added module and returns one rego.Rego option per module,
+// in the order they were added. Every module is processed even after a failure:
+// the returned error joins one *ModuleError per failing module (including a
+// filename added more than once), and no options are returned in that case.
+//
+// Unlike Module, Options has no plain-module fallback: a module that requests
+// nothing from regobrick is added as parsed when it parses cleanly under its
+// RegoVersion, and its syntax errors are collected like any other. Plain v0
+// modules therefore need RegoVersion set to ast.RegoV0; see AddModules.
+func (s *ModuleSet) Options() ([]func(*rego.Rego), error) {
+	opts := make([]func(*rego.Rego), 0, len(s.modules))
+	var errs []error
+	seen := make(map[string]bool, len(s.modules))
+	for _, m := range s.modules {
+		if seen[m.Filename] {
+			errs = append(errs, &ModuleError{Filename: m.Filename, Err: fmt.Errorf("duplicate module filename")})
+			continue
+		}
+		seen[m.Filename] = true
+
+		if m.Cache == nil {
+			m.Cache = s.cache
+		}
+		opt, err := moduleOption(m, false)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		opts = append(opts, opt)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return opts, nil
+}
@@ -0,0 +1,107 @@
+package module
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+)
+
+func TestModuleSet_CollectsAllErrors(t *testing.T) {
+	set := NewModuleSet().
+		Add("ok.rego", "package ok\nallow if input.x\n", nil).
+		Add("typo.rego", "package typo\nimport data.regobrick.default_flase\n", nil).
+		Add("bad_import.rego", "package bad\n", []string{"data..x"}).
+		Add("ok.rego", "package ok2\n", nil)
+
+	opts, err := set.Options()
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if opts != nil {
+		t.Fatalf("expected no options on error, got %d", len(opts))
+	}
+
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		t.Fatalf("expected a joined error, got %T", err)
+	}
+	var files []string
+	for _, e := range joined.Unwrap() {
+		var me *ModuleError
+		if !errors.As(e, &me) {
+			t.Fatalf("expected *ModuleError, got %T: %v", e, e)
+		}
+		files = append(files, me.Filename)
+	}
+	if got := strings.Join(files, ","); got != "typo.rego,bad_import.rego,ok.rego" {
+		t.Fatalf("expected errors for typo.rego, bad_import.rego and duplicate ok.rego, got %s", got)
+	}
+	if !strings.Contains(err.Error(), `cannot process module "typo.rego"`) {
+		t.Errorf("error should use the Module panic format, got: %v", err)
+	}
+}
+
+func TestModuleSet_Options(t *testing.T) {
+	opts, err := NewModuleSet().
+		Add("a.rego", "package a\nimport data.regobrick.default_false\nallow if input.x\n", nil).
+		Add("plain.rego", "package plain\nallow if input.x\n", nil).
+		AddModules(ModuleOption{
+			Filename:    "v0.rego",
+			Source:      "package v0\nallow = true { input.x }\n",
+			RegoVersion: ast.RegoV0,
+		}).
+		Options()
+	if err != nil {
+		t.Fatalf("Options error: %v", err)
+	}
+	if len(opts) != 3 {
+		t.Fatalf("expected 3 options, got %d", len(opts))
+	}
+}
+
+func TestModuleSet_PlainModuleParseErrors(t *testing.T) {
+	_, err := NewModuleSet().
+		Add("ok.rego", "package ok\nallow if input.x\n", nil).
+		Add("broken.rego", "package broken\nallow if {\n", nil).
+		// v0 syntax without RegoVersion does not parse as v1.
+		Add("v0.rego", "package v0\nallow = true { input.x }\n", nil).
+		Options()
+	if err == nil {
+		t.Fatal("expected parse errors for the plain modules, got nil")
+	}
+	var files []string
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var me *ModuleError
+		if !errors.As(e, &me) {
+			t.Fatalf("expected *ModuleError, got %T: %v", e, e)
+		}
+		files = append(files, me.Filename)
+	}
+	if got := strings.Join(files, ","); got != "broken.rego,v0.rego" {
+		t.Errorf("expected errors for broken.rego and v0.rego, got %s", got)
+	}
+}
+
+func TestModuleSet_AddModulesV0(t *testing.T) {
+	_, err := NewModuleSet().
+		AddModules(ModuleOption{
+			Filename:    "v0.rego",
+			Source:      "package v0\nimport data.regobrick.default_false\nallow { input.x }\n",
+			RegoVersion: ast.RegoV0,
+		}).
+		Options()
+	if err != nil {
+		t.Fatalf("Options error: %v", err)
+	}
+
+	// Without RegoVersion the v0 module cannot be processed, since it requested a
+	// feature.
+	_, err = NewModuleSet().
+		Add("v0.rego", "package v0\nimport data.regobrick.default_false\nallow { input.x }\n", nil).
+		Options()
+	if err == nil {
+		t.Fatal("expected v1 parse error, got nil")
+	}
+}
@@ -1,9 +1,11 @@
 // Package module provides utilities for parsing and transforming Rego modules,
 // particularly for detecting and applying regobrick features like "default_false".
+// Features are kept in a registry (see feature.go) that RegisterFeature extends.
 package module
 
 import (
 	"fmt"
+	"regexp"
 	"slices"
 	"strings"
 
@@ -14,61 +16,201 @@ import (
 // rules for boolean rules.
 const featureDefaultFalse = "default_false"
 
+// featureDefaultFalseFunctions is the regobrick feature that inserts
+// "default <f>(_, ...) := false" rules for boolean functions.
+const featureDefaultFalseFunctions = "default_false_functions"
+
+// featureDefaultEmpty is the regobrick feature that inserts "default <rule> := set()"
+// or "default <rule> := {}" rules for set- and object-valued rules.
+const featureDefaultEmpty = "default_empty"
+
 // regobrickImportPrefix is the marker import prefix used to enable regobrick
 // features, e.g. "import data.regobrick.default_false".
 const regobrickImportPrefix = "data.regobrick."
 
-// knownFeatures lists every feature that may appear under regobrickImportPrefix.
-// Any import under that prefix that is not in this list is rejected by ParseModule.
-var knownFeatures = []string{featureDefaultFalse}
+// featureDefaultValue is the regobrick feature that inserts "default <rule> := <value>"
+// rules whose value comes from the rule's "custom: {default: <value>}" METADATA
+// annotation.
+const featureDefaultValue = "default_value"
 
-func isKnownFeature(feature string) bool {
-	return slices.Contains(knownFeatures, feature)
-}
+// defaultValueAnnotationKey is the key under an annotation's "custom" section that
+// holds the default value used by the default_value feature.
+const defaultValueAnnotationKey = "default"
+
+// regobrickAnnotationKey is the key under an annotation's "custom" section that
+// holds per-rule regobrick settings, e.g. "custom: {regobrick: {default_false: false}}".
+const regobrickAnnotationKey = "regobrick"
+
+// generatedAnnotationKey is the key under the "regobrick" custom annotation that
+// marks a rule as synthesized by a feature; its value is the feature name. It is
+// set only by regobrick and is not accepted in policy source.
+const generatedAnnotationKey = "generated"
+
+// knownRuleSettings lists every key that may appear under the "regobrick" custom
+// annotation of a source rule. Any other key is rejected by ParseModule when the
+// module enables at least one feature.
+var knownRuleSettings = []string{featureDefaultFalse}
 
 // ParseModule parses the provided Rego source into an AST module.
-// It optionally appends additional imports, and applies the "default_false"
-// transformation if "import data.regobrick.default_false" is detected.
+// It optionally appends additional imports, and applies the transform of every
+// feature enabled with "import data.regobrick.<feature>": the built-in
+// "default_value", "default_false", "default_false_functions" and
+// "default_empty" features as well as any feature added with RegisterFeature.
 //
 // ParseModule never panics: any failure (parse error, invalid injected import
-// path, import name conflict, or an unknown regobrick feature) is returned as an
-// error.
+// path, import name conflict, an unknown regobrick feature, an invalid
+// default_value or "custom.regobrick" annotation, or a failing feature
+// transform) is returned as an error.
 func ParseModule(filename, source string, imports []string) (*ast.Module, error) {
+	mod, _, err := parseModule(filename, source, ParseOptions{Imports: imports})
+	return mod, err
+}
+
+// ParseOptions configures ParseModuleWithOptions. The zero value behaves like
+// ParseModule without imports. It is exposed publicly as regobrick.ParseOptions.
+type ParseOptions struct {
+	// Imports lists additional import paths to inject, as in ParseModule.
+	Imports []string
+	// RegoVersion selects the Rego syntax of the source, e.g. ast.RegoV0. The
+	// zero value (ast.RegoUndefined) means the v1 syntax. Default rules
+	// synthesized by features use the syntax of this version.
+	RegoVersion ast.RegoVersion
+	// Features lists features to apply as if the module contained
+	// "import data.regobrick.<feature>" for each of them.
+	Features []string
+	// ForbidMarkers rejects modules whose source contains a "data.regobrick.*"
+	// marker import, so that features can only be enabled by the host through
+	// Features (or Imports).
+	ForbidMarkers bool
+	// BigDecimalLiterals makes the decimal_literals feature check number
+	// literals against the limits of BigDecimalBackend (any number of
+	// fractional digits, exponents within ±1000) instead of UDecimalBackend.
+	// Set it when the module is evaluated with BigDecimalBackend.
+	BigDecimalLiterals bool
+}
+
+// ParseModuleWithOptions behaves like ParseModule, with the imports and the Rego
+// version of the source taken from opts. Transforms and import injection work
+// the same on Rego v0 modules as on v1 modules.
+func ParseModuleWithOptions(filename, source string, opts ParseOptions) (*ast.Module, error) {
+	mod, _, err := parseModule(filename, source, opts)
+	return mod, err
+}
+
+// ParseModuleWithReport behaves like ParseModule and additionally returns a
+// Report of every change made to the module: applied features, synthesized
+// default rules, injected and deduplicated imports, and removed marker imports.
+// The report is nil whenever an error is returned.
+func ParseModuleWithReport(filename, source string, imports []string) (*ast.Module, *Report, error) {
+	return ParseModuleWithOptionsReport(filename, source, ParseOptions{Imports: imports})
+}
+
+// ParseModuleWithOptionsReport behaves like ParseModuleWithOptions and
+// additionally returns the Report of ParseModuleWithReport. Features enabled
+// through opts are reported like those enabled by marker imports.
+func ParseModuleWithOptionsReport(filename, source string, opts ParseOptions) (*ast.Module, *Report, error) {
+	return parseModule(filename, source, opts)
+}
+
+// parseModule implements ParseModule, ParseModuleWithOptions and
+// ParseModuleWithOptionsReport.
+func parseModule(filename, source string, opts ParseOptions) (*ast.Module, *Report, error) {
+	report := &Report{Filename: filename}
+
 	// 1) Parse the Rego source. ProcessAnnotation keeps "# METADATA" annotations
 	//    attached to the AST instead of silently dropping them.
-	mod, err := ast.ParseModuleWithOpts(filename, source, ast.ParserOptions{ProcessAnnotation: true})
+	mod, err := ast.ParseModuleWithOpts(filename, source, ast.ParserOptions{
+		ProcessAnnotation: true,
+		RegoVersion:       opts.RegoVersion,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("parse error in %q: %w", filename, err)
+		return nil, nil, fmt.Errorf("parse error in %q: %w", filename, err)
 	}
 	if mod == nil {
-		return nil, fmt.Errorf("got nil module for %q", filename)
+		return nil, nil, fmt.Errorf("got nil module for %q", filename)
 	}
 
-	// 2) Add user-specified imports (e.g., "data.xxx.yyy").
-	for _, path := range imports {
+	// 2) Reject source-level markers when the host enables features itself.
+	if opts.ForbidMarkers {
+		if err := forbidRegobrickMarkers(mod); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// 3) Add user-specified imports (e.g., "data.xxx.yyy").
+	for _, path := range opts.Imports {
+		before := len(mod.Imports)
 		if err := addImport(mod, path); err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+		switch {
+		case path == "":
+		case len(mod.Imports) > before:
+			report.InjectedImports = append(report.InjectedImports, path)
+		default:
+			report.DeduplicatedImports = append(report.DeduplicatedImports, path)
 		}
 	}
 
-	// 3) Reject unknown regobrick feature imports so a typo like
+	// 4) Reject unknown regobrick feature imports so a typo like
 	//    "data.regobrick.default_flase" fails loudly instead of silently doing
 	//    nothing. This runs after import injection so both source imports and
-	//    injected imports are validated.
+	//    injected imports are validated, and covers the features passed in opts.
 	if err := validateRegobrickFeatures(mod); err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	names := regobrickFeatures(mod)
+	for _, name := range opts.Features {
+		if !isKnownFeature(name) {
+			return nil, nil, fmt.Errorf(
+				"regobrick: unknown feature %q (known features: %s)",
+				name, strings.Join(knownFeatureNames(), ", "),
+			)
+		}
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
 	}
 
-	// 4) If the module imports "data.regobrick.default_false", apply default_false transform.
-	if hasRegobrickFeature(mod, featureDefaultFalse) {
-		addDefaultFalse(mod)
+	// 5) Apply the transform of every enabled feature. resolveFeatures adds
+	//    required features and fixes a deterministic order, e.g. default_value
+	//    runs before default_false so that an annotated default wins. The
+	//    "custom.regobrick" settings of the source rules are validated first,
+	//    whichever features are enabled, so a misspelled setting never goes
+	//    unnoticed; a plain module with no feature is left to OPA.
+	enabled, err := resolveFeatures(names)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(enabled) > 0 {
+		if err := validateRuleSettings(mod); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, f := range enabled {
+		snapshot := snapshotRules(mod)
+		if err := f.apply(mod, opts); err != nil {
+			return nil, nil, err
+		}
+		report.Features = append(report.Features, f.name)
+		report.RuleChanges = append(report.RuleChanges, snapshot.diff(f.name, mod)...)
+		for _, r := range mod.Rules {
+			if _, ok := snapshot.copies[r]; ok || !r.Default || r.Head == nil || r.Head.Value == nil {
+				continue
+			}
+			report.DefaultRules = append(report.DefaultRules, DefaultRule{
+				Feature: f.name,
+				Ref:     r.Head.Ref().String(),
+				Value:   r.Head.Value.String(),
+			})
+		}
 	}
 
-	// 5) Drop the regobrick marker imports. They only trigger transforms and are
+	// 6) Drop the regobrick marker imports. They only trigger transforms and are
 	//    unused in the resulting AST, which would otherwise break rego.Strict(true).
-	removeRegobrickImports(mod)
+	report.RemovedMarkers = removeRegobrickImports(mod)
 
-	return mod, nil
+	return mod, report, nil
 }
 
 // validateRegobrickFeatures returns an error if the module imports anything under
@@ -87,43 +229,64 @@ func validateRegobrickFeatures(mod *ast.Module) error {
 		if !isKnownFeature(feature) {
 			return fmt.Errorf(
 				"regobrick: unknown feature import %q (known features: %s)",
-				s, strings.Join(knownFeatures, ", "),
+				s, strings.Join(knownFeatureNames(), ", "),
+			)
+		}
+	}
+	return nil
+}
+
+// forbidRegobrickMarkers returns an error for the first "data.regobrick.*" marker
+// import of mod. It runs before import injection, so only source-level markers
+// are rejected.
+func forbidRegobrickMarkers(mod *ast.Module) error {
+	for _, imp := range mod.Imports {
+		if ref, ok := imp.Path.Value.(ast.Ref); ok && strings.HasPrefix(ref.String(), regobrickImportPrefix) {
+			return fmt.Errorf(
+				"regobrick: marker import %q is not allowed at %v: features are enabled by the host",
+				ref.String(), imp.Location,
 			)
 		}
 	}
 	return nil
 }
 
-// removeRegobrickImports strips every "data.regobrick.*" marker import from the module.
-func removeRegobrickImports(mod *ast.Module) {
+// removeRegobrickImports strips every "data.regobrick.*" marker import from the
+// module and returns the paths of the removed imports.
+func removeRegobrickImports(mod *ast.Module) []string {
+	var removed []string
 	kept := mod.Imports[:0]
 	for _, imp := range mod.Imports {
 		if ref, ok := imp.Path.Value.(ast.Ref); ok && strings.HasPrefix(ref.String(), regobrickImportPrefix) {
+			removed = append(removed, ref.String())
 			continue
 		}
 		kept = append(kept, imp)
 	}
 	mod.Imports = kept
+	return removed
 }
 
-// addImport parses importPath into an ast.Ref and appends it as an import to the
-// module. It deduplicates against existing imports using Ref equality, and reports
-// an error when the path is invalid or when it would shadow an existing import that
-// binds the same local name to a different reference.
+// addImport parses importPath — a reference, optionally followed by "as <alias>"
+// — and appends it as an import to the module. It deduplicates against existing
+// imports of the same reference that bind the same local name (the alias, or the
+// last path segment when there is none), and reports an error when the path or
+// alias is invalid or when the import would shadow an existing import that binds
+// the same local name to a different reference.
 func addImport(mod *ast.Module, importPath string) error {
 	if importPath == "" {
 		return nil
 	}
 
-	ref, err := ast.ParseRef(importPath)
+	ref, alias, err := parseImportPath(importPath)
 	if err != nil {
-		return fmt.Errorf("regobrick: invalid import path %q: %w", importPath, err)
+		return err
 	}
 	if !isImportRef(ref) {
 		return fmt.Errorf("regobrick: invalid import path %q: import must be a reference to a data path", importPath)
 	}
 
-	newImp := &ast.Import{Path: ast.NewTerm(ref)}
+	newImp := &ast.Import{Path: ast.NewTerm(ref), Alias: alias}
 	newName := newImp.Name()
 
 	for _, imp := range mod.Imports {
@@ -132,12 +295,13 @@ func addImport(mod *ast.Module, importPath string) error {
 			continue
 		}
 		if existingRef.Equal(ref) {
-			// Same target. Deduplicate when the existing import binds the default
-			// local name (no alias, or an alias equal to the default name).
-			if imp.Alias == "" || imp.Alias.Equal(newName) {
+			// Same target. Deduplicate when the existing import binds the same
+			// local name (e.g. "import data.lib.money" vs an injected
+			// "data.lib.money as money").
+			if imp.Name().Equal(newName) {
 				return nil
 			}
-			// Existing import is aliased to a different name (e.g. "as h"); keep both.
+			// Bound to a different name (e.g. "as h"); keep both.
 			continue
 		}
 		// Different target but the same local binding name -> unresolvable shadow.
@@ -153,6 +317,42 @@ func addImport(mod *ast.Module, importPath string) error {
 	return nil
 }
 
+// importAliasPattern matches the trailing "as <alias>" of an injected import
+// path. It only serves to name a malformed alias in the error of
+// parseImportPath; the path itself is split by the OPA parser, so an "as"
+// inside a quoted segment such as data.x["a as b"] is never taken for an alias.
+var importAliasPattern = regexp.MustCompile(`\sas\s+(\S+)\s*$`)
+
+// importAliasNamePattern restricts aliases to Rego identifiers.
+var importAliasNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// parseImportPath parses "data.lib.money as m" with the OPA parser, as if it
+// followed the "import" keyword, and returns its reference and alias. A path
+// without an "as" clause has an empty alias.
+func parseImportPath(importPath string) (ast.Ref, ast.Var, error) {
+	imports, err := ast.ParseImports("import " + importPath)
+	if err == nil && len(imports) != 1 {
+		err = fmt.Errorf("expected a single import, got %d", len(imports))
+	}
+	if err != nil {
+		if m := importAliasPattern.FindStringSubmatch(importPath); m != nil {
+			alias := m[1]
+			if !importAliasNamePattern.MatchString(alias) {
+				return nil, "", fmt.Errorf("regobrick: invalid import alias %q in %q: must be a Rego identifier", alias, importPath)
+			}
+			if ast.RootDocumentNames.Contains(ast.VarTerm(alias)) {
+				return nil, "", fmt.Errorf("regobrick: invalid import alias %q in %q: cannot shadow a root document", alias, importPath)
+			}
+		}
+		return nil, "", fmt.Errorf("regobrick: invalid import path %q: %w", importPath, err)
+	}
+	ref, ok := imports[0].Path.Value.(ast.Ref)
+	if !ok {
+		return nil, "", fmt.Errorf("regobrick: invalid import path %q: import must be a reference to a data path", importPath)
+	}
+	return ref, imports[0].Alias, nil
+}
+
 // isImportRef reports whether ref has the shape of a valid import path: a "data"
 // or "input" head followed by String terms (e.g. data.foo, data["foo-bar"].baz).
 // Rego imports must be rooted at data or input.
@@ -172,25 +372,51 @@ func isImportRef(ref ast.Ref) bool {
 	return true
 }
 
-// hasRegobrickFeature returns true if the module imports "data.regobrick.<feature>".
-func hasRegobrickFeature(mod *ast.Module, feature string) bool {
-	target := regobrickImportPrefix + feature
+// regobrickFeatures returns the features enabled by "data.regobrick.<feature>"
+// imports in the module, in import order and without duplicates.
+func regobrickFeatures(mod *ast.Module) []string {
+	var names []string
 	for _, imp := range mod.Imports {
-		if ref, ok := imp.Path.Value.(ast.Ref); ok && ref.String() == target {
-			return true
+		ref, ok := imp.Path.Value.(ast.Ref)
+		if !ok {
+			continue
+		}
+		s := ref.String()
+		if !strings.HasPrefix(s, regobrickImportPrefix) {
+			continue
+		}
+		name := strings.TrimPrefix(s, regobrickImportPrefix)
+		if !slices.Contains(names, name) {
+			names = append(names, name)
 		}
 	}
-	return false
+	return names
 }
 
 // addDefaultFalse inserts a "default <rule> = false" rule for each boolean rule without an existing default.
 // A boolean rule is one where Head.Key is nil and Head.Value is nil or Boolean type.
 // Complete rules (e.g., x := 1) are excluded since their Head.Value is a non-boolean type.
-func addDefaultFalse(mod *ast.Module) {
+//
+// A rule opts out with a "custom: {regobrick: {default_false: false}}" METADATA
+// annotation, leaving it undefined when no body succeeds. Opting out one
+// definition of an incrementally defined rule opts out the whole rule, since the
+// default applies to the rule's ref. An invalid "regobrick" annotation on any rule
+// is returned as an error; ParseModule reports it for every module, see
+// validateRuleSettings.
+func addDefaultFalse(mod *ast.Module) error {
 	existing := make(map[string]bool)
+	optedOut := make(map[string]bool)
 	for _, r := range mod.Rules {
 		if r.Default {
 			existing[r.Head.Ref().String()] = true
+			continue
+		}
+		out, err := defaultFalseOptedOut(r)
+		if err != nil {
+			return err
+		}
+		if out {
+			optedOut[r.Head.Ref().String()] = true
 		}
 	}
 
@@ -200,43 +426,410 @@ func addDefaultFalse(mod *ast.Module) {
 			continue
 		}
 
-		// Detect a boolean rule:
-		// - Head.Key is nil (not a partial rule)
-		// - Head.Value is nil or of Boolean type
-		// Complete rules (x := 1) are excluded because their Head.Value is a
-		// Number/String, etc.
-		isBooleanRule := false
-		if r.Head.Key == nil {
-			if r.Head.Value == nil {
-				isBooleanRule = true
-			} else if _, ok := r.Head.Value.Value.(ast.Boolean); ok {
-				isBooleanRule = true
-			}
-		}
-
-		if isBooleanRule {
+		if isBooleanHead(r.Head) {
 			refVal := r.Head.Ref()
 			if refVal == nil {
 				continue
 			}
 			refStr := refVal.String()
 
-			// Skip if there's already a default rule for this reference.
-			if existing[refStr] {
+			// Skip if there's already a default rule for this reference, or if the
+			// rule opted out via its METADATA annotation.
+			if existing[refStr] || optedOut[refStr] {
 				continue
 			}
 
-			// Create a default rule, e.g. "default if = false".
-			newHead := &ast.Head{
-				Reference: refVal,
-				Value:     ast.BooleanTerm(false),
-			}
-			newRule := &ast.Rule{
-				Default: true,
-				Head:    newHead,
-			}
+			// Create a default rule, e.g. "default allow = false".
+			newRule := newDefaultRule(featureDefaultFalse, mod, r, ast.BooleanTerm(false))
 			mod.Rules = append(mod.Rules, newRule)
 			existing[refStr] = true
 		}
 	}
+	return nil
+}
+
+// isBooleanHead reports whether h is the head of a boolean rule or function:
+// - Head.Key is nil (not a partial rule)
+// - Head.Value is nil or of Boolean type
+// Complete rules (x := 1) are excluded because their Head.Value is a
+// Number/String, etc.
+func isBooleanHead(h *ast.Head) bool {
+	if h.Key != nil {
+		return false
+	}
+	if h.Value == nil {
+		return true
+	}
+	_, ok := h.Value.Value.(ast.Boolean)
+	return ok
+}
+
+// addDefaultFalseFunctions inserts an arity-matching "default f(_, _) := false"
+// rule for each boolean function without an existing default, so that a call
+// whose bodies all fail returns false instead of being undefined.
+//
+// The definitions of a function are handled as a whole: no default is added if
+// any definition has a non-boolean value (e.g. "f(x) := 1"), opts out with a
+// "custom: {regobrick: {default_false: false}}" METADATA annotation, or if the
+// module already declares a default for the function.
+func addDefaultFalseFunctions(mod *ast.Module) error {
+	skip := make(map[string]bool)
+	for _, r := range mod.Rules {
+		refStr := r.Head.Ref().String()
+		if r.Default {
+			skip[refStr] = true
+			continue
+		}
+		if len(r.Head.Args) == 0 {
+			continue
+		}
+		out, err := defaultFalseOptedOut(r)
+		if err != nil {
+			return err
+		}
+		if out || !isBooleanHead(r.Head) {
+			skip[refStr] = true
+		}
+	}
+
+	for _, r := range mod.Rules {
+		if r.Default || len(r.Head.Args) == 0 {
+			continue
+		}
+		refStr := r.Head.Ref().String()
+		if skip[refStr] {
+			continue
+		}
+		newRule := newDefaultRule(featureDefaultFalseFunctions, mod, r, ast.BooleanTerm(false))
+		newRule.Head.Args = make(ast.Args, len(r.Head.Args))
+		for i := range newRule.Head.Args {
+			// Wildcards, rendered as "_" by the formatter.
+			newRule.Head.Args[i] = ast.VarTerm(fmt.Sprintf("%s%d", ast.WildcardPrefix, i))
+			locateTerm(newRule.Head.Args[i], r.Location)
+		}
+		mod.Rules = append(mod.Rules, newRule)
+		skip[refStr] = true
+	}
+	return nil
+}
+
+// validateRuleSettings checks the "custom.regobrick" annotation of every rule of
+// a module that enables a feature: it must be a map of known settings with values of the right type. The
+// generated marker is reserved for rules synthesized by regobrick, so that
+// GeneratedBy never reports a source rule.
+func validateRuleSettings(mod *ast.Module) error {
+	for _, r := range mod.Rules {
+		for _, a := range r.Annotations {
+			if m, ok := a.Custom[regobrickAnnotationKey].(map[string]any); ok {
+				if _, ok := m[generatedAnnotationKey]; ok {
+					return fmt.Errorf(
+						"regobrick: invalid %q annotation on rule %q at %v: %q is reserved for rules synthesized by regobrick",
+						"custom."+regobrickAnnotationKey, r.Head.Ref().String(), r.Location, generatedAnnotationKey,
+					)
+				}
+			}
+		}
+		if _, err := defaultFalseOptedOut(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// defaultFalseOptedOut reports whether r opts out of the default_false transform
+// through a "custom: {regobrick: {default_false: false}}" METADATA annotation.
+func defaultFalseOptedOut(r *ast.Rule) (bool, error) {
+	settings, err := ruleSettings(r)
+	if err != nil {
+		return false, err
+	}
+	v, ok := settings[featureDefaultFalse]
+	if !ok {
+		return false, nil
+	}
+	enabled, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf(
+			"regobrick: invalid %q annotation on rule %q at %v: %q must be a boolean, got %v",
+			"custom."+regobrickAnnotationKey, r.Head.Ref().String(), r.Location, featureDefaultFalse, v,
+		)
+	}
+	return !enabled, nil
+}
+
+// ruleSettings returns the per-rule regobrick settings declared under the
+// "regobrick" key of the "custom" section of r's METADATA annotations. It returns
+// an error when the section is not a map or contains a key that is not listed in
+// knownRuleSettings.
+func ruleSettings(r *ast.Rule) (map[string]any, error) {
+	var settings map[string]any
+	for _, a := range r.Annotations {
+		raw, ok := a.Custom[regobrickAnnotationKey]
+		if !ok {
+			continue
+		}
+		m, ok := raw.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf(
+				"regobrick: invalid %q annotation on rule %q at %v: expected a map, got %v",
+				"custom."+regobrickAnnotationKey, r.Head.Ref().String(), r.Location, raw,
+			)
+		}
+		for k, v := range m {
+			if !slices.Contains(knownRuleSettings, k) {
+				return nil, fmt.Errorf(
+					"regobrick: invalid %q annotation on rule %q at %v: unknown setting %q (known settings: %s)",
+					"custom."+regobrickAnnotationKey, r.Head.Ref().String(), r.Location, k,
+					strings.Join(knownRuleSettings, ", "),
+				)
+			}
+			if settings == nil {
+				settings = make(map[string]any)
+			}
+			settings[k] = v
+		}
+	}
+	return settings, nil
+}
+
+// addDefaultEmpty inserts a "default <rule> := set()" or "default <rule> := {}" rule for
+// each set- or object-valued complete rule without an existing default. A rule
+// qualifies when its ref is ground, it has no arguments, and its Head.Value is a
+// set/object literal or comprehension, e.g. "admins := {u | ...} if input.enabled",
+// or a variable the body assigns one to, e.g. "admins := a if { a := {u | ...} }".
+//
+// Partial set rules ("deny contains msg if ...") and partial object rules
+// ("obj[k] := v if ...") are left untouched: OPA already evaluates them to an
+// empty set or object when no body succeeds, and it rejects a default rule next
+// to them as a "conflicting rules" error, so there is nothing a default could
+// add. Ref-head rules are supported as long as
+// their ref is ground (e.g. "limits.by_region := {...} if ...").
+func addDefaultEmpty(mod *ast.Module) {
+	existing := make(map[string]bool)
+	for _, r := range mod.Rules {
+		if r.Default {
+			existing[r.Head.Ref().String()] = true
+		}
+	}
+
+	for _, r := range mod.Rules {
+		if r.Default {
+			continue
+		}
+
+		empty := emptyValueFor(r)
+		if empty == nil {
+			continue
+		}
+
+		refVal := r.Head.Ref()
+		refStr := refVal.String()
+		if existing[refStr] {
+			continue
+		}
+
+		newRule := newDefaultRule(featureDefaultEmpty, mod, r, empty)
+		mod.Rules = append(mod.Rules, newRule)
+		existing[refStr] = true
+	}
+}
+
+// emptyValueFor returns the empty set or empty object term that default_empty
+// should use as the default value of r, or nil if r is not a set- or
+// object-valued complete rule.
+func emptyValueFor(r *ast.Rule) *ast.Term {
+	if len(r.Head.Args) > 0 || r.Head.Key != nil || r.Head.Value == nil {
+		return nil
+	}
+	refVal := r.Head.Ref()
+	if refVal == nil || !refVal.IsGround() {
+		return nil
+	}
+	value := r.Head.Value
+	if v, ok := value.Value.(ast.Var); ok {
+		value = bodyBinding(r.Body, v)
+		if value == nil {
+			return nil
+		}
+	}
+	switch value.Value.(type) {
+	case ast.Set, *ast.SetComprehension:
+		return ast.SetTerm()
+	case ast.Object, *ast.ObjectComprehension:
+		return ast.ObjectTerm()
+	}
+	return nil
+}
+
+// bodyBinding returns the term that a top-level "v := <term>" or "v = <term>"
+// expression of body binds to v, or nil if there is none.
+func bodyBinding(body ast.Body, v ast.Var) *ast.Term {
+	for _, expr := range body {
+		if expr.Negated || len(expr.With) > 0 || !(expr.IsAssignment() || expr.IsEquality()) {
+			continue
+		}
+		lhs, rhs := expr.Operand(0), expr.Operand(1)
+		if lhs.Value.Compare(v) == 0 {
+			return rhs
+		}
+		if expr.IsEquality() && rhs.Value.Compare(v) == 0 {
+			return lhs
+		}
+	}
+	return nil
+}
+
+// addDefaultValue inserts a "default <rule> := <value>" rule for each rule whose
+// METADATA annotation carries a "custom: {default: <value>}" entry, e.g.
+//
+//	# METADATA
+//	# custom:
+//	#   default: 0
+//	discount := input.amount * 0.1 if input.member
+//
+// The value is converted with ast.InterfaceToValue, so any YAML scalar, list or
+// map is accepted. OPA decodes every YAML number, integers included, as a
+// float64 before conversion, so integers beyond 2^53 and decimals that need more
+// than float64 precision lose digits and should be computed in Rego instead.
+//
+// Unlike default_false, the default is an explicit request from the policy
+// author, so every case that cannot honor it is reported as an error: function
+// rules, partial rules and rules with a non-ground ref, a value that cannot be
+// converted, rules of the same ref annotated with different values, and rules
+// that already have an explicit "default" rule.
+func addDefaultValue(mod *ast.Module) error {
+	existing := make(map[string]bool)
+	for _, r := range mod.Rules {
+		if r.Default {
+			existing[r.Head.Ref().String()] = true
+		}
+	}
+
+	added := make(map[string]*ast.Term)
+	for _, r := range mod.Rules {
+		if r.Default {
+			continue
+		}
+		raw, ok := annotatedDefault(r)
+		if !ok {
+			continue
+		}
+
+		refVal := r.Head.Ref()
+		refStr := refVal.String()
+		switch {
+		case len(r.Head.Args) > 0:
+			return fmt.Errorf("regobrick: default_value: function %q at %v cannot have an annotated default", refStr, r.Location)
+		case r.Head.Key != nil || !refVal.IsGround():
+			return fmt.Errorf("regobrick: default_value: partial rule %q at %v cannot have an annotated default", refStr, r.Location)
+		case existing[refStr]:
+			return fmt.Errorf("regobrick: default_value: rule %q at %v already has an explicit default rule", refStr, r.Location)
+		}
+
+		value, err := ast.InterfaceToValue(raw)
+		if err != nil {
+			return fmt.Errorf("regobrick: default_value: invalid default for rule %q at %v: %w", refStr, r.Location, err)
+		}
+		term := ast.NewTerm(value)
+
+		if prev, ok := added[refStr]; ok {
+			if !prev.Equal(term) {
+				return fmt.Errorf(
+					"regobrick: default_value: rule %q at %v has conflicting annotated defaults %v and %v",
+					refStr, r.Location, prev, term,
+				)
+			}
+			continue
+		}
+
+		mod.Rules = append(mod.Rules, newDefaultRule(featureDefaultValue, mod, r, term))
+		added[refStr] = term
+	}
+	return nil
+}
+
+// annotatedDefault returns the value of the "default" key under the "custom"
+// section of r's METADATA annotations, if any.
+func annotatedDefault(r *ast.Rule) (any, bool) {
+	for _, a := range r.Annotations {
+		if v, ok := a.Custom[defaultValueAnnotationKey]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// newDefaultRule builds "default <ref> := <value>" for the rule orig of mod, on
+// behalf of feature. In a Rego v0 module it builds "default <ref> = <value>"
+// instead, matching the syntax of that version.
+//
+// The rule, its head and its value are located at orig, so compiler errors,
+// coverage reports and traces involving the default point at the rule it
+// defaults; see synthesizedLocation. The ref is copied rather than shared with
+// orig, since the compiler rewrites terms in place. A
+// "custom: {regobrick: {generated: <feature>}}" annotation marks the rule as
+// generated; see GeneratedBy.
+func newDefaultRule(feature string, mod *ast.Module, orig *ast.Rule, value *ast.Term) *ast.Rule {
+	loc := synthesizedLocation(orig.Location, nil)
+	locateTerm(value, orig.Location)
+
+	return &ast.Rule{
+		Default: true,
+		Head: &ast.Head{
+			Reference: orig.Head.Ref().Copy(),
+			Value:     value,
+			Assign:    usesAssign(mod),
+			Location:  loc,
+		},
+		Location: loc,
+		Annotations: []*ast.Annotations{{
+			Scope: "rule",
+			Custom: map[string]any{
+				regobrickAnnotationKey: map[string]any{generatedAnnotationKey: feature},
+			},
+			Location: loc,
+		}},
+	}
+}
+
+// synthesizedLocation returns the location of a node synthesized on behalf of
+// the source node at loc: the same file, row and column, with text as its source
+// text. OPA's formatter prints some terms, such as strings, from the text of
+// their location, so a synthesized node must not carry the text of loc. It
+// returns nil if loc is nil.
+func synthesizedLocation(loc *ast.Location, text []byte) *ast.Location {
+	if loc == nil {
+		return nil
+	}
+	return &ast.Location{File: loc.File, Row: loc.Row, Col: loc.Col, Text: text}
+}
+
+// locateTerm places the synthesized term t and every term nested in it at loc,
+// each with its own rendering as text; see synthesizedLocation.
+func locateTerm(t *ast.Term, loc *ast.Location) {
+	ast.WalkTerms(t, func(nested *ast.Term) bool {
+		nested.Location = synthesizedLocation(loc, []byte(nested.String()))
+		return false
+	})
+}
+
+// usesAssign reports whether rules synthesized for mod use ":=" rather than "=":
+// every Rego version except v0 does.
+func usesAssign(mod *ast.Module) bool {
+	return mod.RegoVersion() != ast.RegoV0
+}
+
+// GeneratedBy reports whether r was synthesized by a regobrick feature, and if so
+// which one, based on the annotation set by newDefaultRule.
+func GeneratedBy(r *ast.Rule) (string, bool) {
+	for _, a := range r.Annotations {
+		settings, ok := a.Custom[regobrickAnnotationKey].(map[string]any)
+		if !ok {
+			continue
+		}
+		if feature, ok := settings[generatedAnnotationKey].(string); ok {
+			return feature, true
+		}
+	}
+	return "", false
 }
@@ -1,10 +1,12 @@
 package module
 
 import (
+	"context"
 	"strings"
 	"testing"
 
 	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/open-policy-agent/opa/v1/rego"
 )
 
 // TestAddDefaultFalse_BooleanRule verifies that a default is added to a boolean rule
@@ -178,6 +180,106 @@ result := helper.value
 	}
 }
 
+func TestParseModule_InjectsAliasedImport(t *testing.T) {
+	// Two libraries ending in the same segment: the alias avoids the
+	// "both bind name" conflict.
+	source := `package test
+import data.lib.money
+
+result := money2.add(money.x, 1)
+`
+	mod, err := ParseModule("test.rego", source, []string{"data.lib.v2.money as money2"})
+	if err != nil {
+		t.Fatalf("ParseModule error: %v", err)
+	}
+
+	found := false
+	for _, imp := range mod.Imports {
+		if imp.Path.String() == "data.lib.v2.money" && imp.Alias == "money2" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expected aliased import data.lib.v2.money as money2, got %v", mod.Imports)
+	}
+}
+
+func TestParseModule_AliasedImportDedupAndConflicts(t *testing.T) {
+	source := `package test
+import data.lib.money as m
+
+result := m.x
+`
+	tests := []struct {
+		name    string
+		imports []string
+		count   int    // imports of data.lib.money after injection
+		errSub  string // expected error substring, if any
+	}{
+		{"same_alias_dedup", []string{"data.lib.money as m"}, 1, ""},
+		{"plain_kept_next_to_alias", []string{"data.lib.money"}, 2, ""},
+		{"other_alias_kept", []string{"data.lib.money as m2"}, 2, ""},
+		{"repeated_alias_dedup", []string{"data.lib.money as m2", "data.lib.money as m2"}, 2, ""},
+		{"alias_conflict", []string{"data.lib.v2.money as m"}, 0, `both bind name "m"`},
+		{"injected_alias_conflict", []string{"data.a as x", "data.b as x"}, 0, `both bind name "x"`},
+		{"invalid_alias", []string{"data.lib.money as 1m"}, 0, "invalid import alias"},
+		{"root_alias", []string{"data.lib.money as input"}, 0, "invalid import alias"},
+		{"missing_alias", []string{"data.lib.money as"}, 0, "invalid import path"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mod, err := ParseModule("test.rego", source, tt.imports)
+			if tt.errSub != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errSub) {
+					t.Fatalf("expected error containing %q, got: %v", tt.errSub, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseModule error: %v", err)
+			}
+			count := 0
+			for _, imp := range mod.Imports {
+				if imp.Path.String() == "data.lib.money" {
+					count++
+				}
+			}
+			if count != tt.count {
+				t.Errorf("expected %d imports of data.lib.money, got %v", tt.count, mod.Imports)
+			}
+		})
+	}
+}
+
+// TestParseModule_QuotedSegmentWithAs checks that an "as" inside a quoted path
+// segment is part of the path, not an alias
+func TestParseModule_QuotedSegmentWithAs(t *testing.T) {
+	tests := []struct {
+		path  string
+		ref   string
+		alias ast.Var
+	}{
+		{`data.x["a as b"]`, `data.x["a as b"]`, ""},
+		{`data.x["a as b"] as c`, `data.x["a as b"]`, "c"},
+		{`data.x["as"].y as z`, `data.x["as"].y`, "z"},
+	}
+	for _, tt := range tests {
+		mod, err := ParseModule("test.rego", "package test\n", []string{tt.path})
+		if err != nil {
+			t.Errorf("%s: ParseModule error: %v", tt.path, err)
+			continue
+		}
+		if len(mod.Imports) != 1 || mod.Imports[0].Path.String() != tt.ref || mod.Imports[0].Alias != tt.alias {
+			t.Errorf("%s: expected import %s as %q, got %v", tt.path, tt.ref, tt.alias, mod.Imports)
+		}
+	}
+
+	if _, err := ParseModule("test.rego", "package test\n", []string{"data.x\nimport data.y"}); err == nil ||
+		!strings.Contains(err.Error(), "invalid import path") {
+		t.Errorf("expected a single-import error, got: %v", err)
+	}
+}
+
 func TestParseModule_DeduplicatesRepeatedInjectedImports(t *testing.T) {
 	source := `package test
 
@@ -692,3 +794,892 @@ x := true if { input.y }
 		t.Error("boolean assignment 'x := true if {...}' SHOULD have default")
 	}
 }
+
+// =============================================================================
+// default_empty feature
+// =============================================================================
+
+// TestAddDefaultEmpty_SetAndObjectRules set- and object-valued complete rules get an empty default
+func TestAddDefaultEmpty_SetAndObjectRules(t *testing.T) {
+	source := `package test
+import data.regobrick.default_empty
+
+admins := {u | some u in input.users; u.admin} if input.enabled
+limits := {"eu": 10} if input.enabled
+tags := {"a", "b"} if input.enabled
+name := "x" if input.enabled
+`
+	mod, err := ParseModule("test.rego", source, nil)
+	if err != nil {
+		t.Fatalf("ParseModule error: %v", err)
+	}
+
+	defaults := make(map[string]*ast.Term)
+	for _, r := range mod.Rules {
+		if r.Default {
+			defaults[r.Head.Ref().String()] = r.Head.Value
+		}
+	}
+
+	for _, name := range []string{"admins", "tags"} {
+		v, ok := defaults[name]
+		if !ok {
+			t.Errorf("expected default for %q", name)
+			continue
+		}
+		if s, ok := v.Value.(ast.Set); !ok || s.Len() != 0 {
+			t.Errorf("expected empty set default for %q, got %v", name, v)
+		}
+	}
+	if v, ok := defaults["limits"]; !ok {
+		t.Error("expected default for 'limits'")
+	} else if o, ok := v.Value.(ast.Object); !ok || o.Len() != 0 {
+		t.Errorf("expected empty object default for 'limits', got %v", v)
+	}
+	if _, ok := defaults["name"]; ok {
+		t.Error("unexpected default for string-valued rule 'name'")
+	}
+}
+
+// TestAddDefaultEmpty_PartialRulesUntouched partial set/object rules are already
+// empty when no body succeeds, so no (conflicting) default is added
+func TestAddDefaultEmpty_PartialRulesUntouched(t *testing.T) {
+	source := `package test
+import data.regobrick.default_empty
+
+deny contains msg if { input.blocked; msg := "blocked" }
+obj[k] := v if { k := input.k; v := 1 }
+`
+	mod, err := ParseModule("test.rego", source, nil)
+	if err != nil {
+		t.Fatalf("ParseModule error: %v", err)
+	}
+	for _, r := range mod.Rules {
+		if r.Default {
+			t.Errorf("unexpected default rule for partial rule: %v", r.Head.Ref())
+		}
+	}
+
+	rs, err := rego.New(
+		rego.ParsedModule(mod),
+		rego.Query("data.test"),
+		rego.Input(map[string]any{}),
+	).Eval(context.Background())
+	if err != nil {
+		t.Fatalf("Eval error: %v", err)
+	}
+	got := rs[0].Expressions[0].Value.(map[string]any)
+	if deny, ok := got["deny"].([]any); !ok || len(deny) != 0 {
+		t.Errorf("expected deny to evaluate to an empty set, got %v", got["deny"])
+	}
+	if obj, ok := got["obj"].(map[string]any); !ok || len(obj) != 0 {
+		t.Errorf("expected obj to evaluate to an empty object, got %v", got["obj"])
+	}
+}
+
+// TestAddDefaultEmpty_VariableValue a rule whose value is a variable bound to a
+// set or object in the body gets an empty default
+func TestAddDefaultEmpty_VariableValue(t *testing.T) {
+	source := `package test
+import data.regobrick.default_empty
+
+admins := a if {
+	input.enabled
+	a := {u | some u in input.users}
+}
+limits := l if {
+	input.enabled
+	{"eu": 10} = l
+}
+name := n if {
+	n := "x"
+	input.enabled
+}
+count_users := c if {
+	c := count(input.users)
+}
+`
+	mod, err := ParseModule("test.rego", source, nil)
+	if err != nil {
+		t.Fatalf("ParseModule error: %v", err)
+	}
+
+	defaults := make(map[string]*ast.Term)
+	for _, r := range mod.Rules {
+		if r.Default {
+			defaults[r.Head.Ref().String()] = r.Head.Value
+		}
+	}
+	if v := defaults["admins"]; v == nil || !v.Equal(ast.SetTerm()) {
+		t.Errorf("expected default admins := set(), got %v", v)
+	}
+	if v := defaults["limits"]; v == nil || !v.Equal(ast.ObjectTerm()) {
+		t.Errorf("expected default limits := {}, got %v", v)
+	}
+	for _, name := range []string{"name", "count_users"} {
+		if v, ok := defaults[name]; ok {
+			t.Errorf("unexpected default for %q: %v", name, v)
+		}
+	}
+}
+
+// TestAddDefaultEmpty_RefHeadAndExistingDefault ground ref-head rules get a default,
+// existing defaults are respected
+func TestAddDefaultEmpty_RefHeadAndExistingDefault(t *testing.T) {
+	source := `package test
+import data.regobrick.default_empty
+
+default roles := {"guest"}
+roles := {r | some r in input.roles} if input.roles
+
+limits.by_region := {"eu": 10} if input.enabled
+`
+	mod, err := ParseModule("test.rego", source, nil)
+	if err != nil {
+		t.Fatalf("ParseModule error: %v", err)
+	}
+
+	defaults := make(map[string]int)
+	for _, r := range mod.Rules {
+		if r.Default {
+			defaults[r.Head.Ref().String()]++
+		}
+	}
+	if defaults["roles"] != 1 {
+		t.Errorf("expected existing default for 'roles' to be kept as the only one, got %d", defaults["roles"])
+	}
+	if defaults["limits.by_region"] != 1 {
+		t.Errorf("expected 1 default for ref-head rule 'limits.by_region', got %d", defaults["limits.by_region"])
+	}
+}
+
+// TestAddDefaultEmpty_WithDefaultFalse both features apply side by side
+func TestAddDefaultEmpty_WithDefaultFalse(t *testing.T) {
+	source := `package test
+import data.regobrick.default_false
+import data.regobrick.default_empty
+
+allow if { input.x }
+admins := {u | some u in input.users} if input.enabled
+`
+	mod, err := ParseModule("test.rego", source, nil)
+	if err != nil {
+		t.Fatalf("ParseModule error: %v", err)
+	}
+
+	defaults := make(map[string]*ast.Term)
+	for _, r := range mod.Rules {
+		if r.Default {
+			defaults[r.Head.Ref().String()] = r.Head.Value
+		}
+	}
+	if v := defaults["allow"]; v == nil || !v.Equal(ast.BooleanTerm(false)) {
+		t.Errorf("expected default allow = false, got %v", v)
+	}
+	if v := defaults["admins"]; v == nil || !v.Equal(ast.SetTerm()) {
+		t.Errorf("expected default admins := set(), got %v", v)
+	}
+	if len(mod.Imports) != 0 {
+		t.Errorf("expected both marker imports to be removed, got %v", mod.Imports)
+	}
+}
+
+// =============================================================================
+// default_value feature
+// =============================================================================
+
+// TestAddDefaultValue_FromAnnotation the annotated custom default becomes a default rule
+func TestAddDefaultValue_FromAnnotation(t *testing.T) {
+	source := `package test
+import data.regobrick.default_value
+
+# METADATA
+# custom:
+#   default: 0
+discount := input.amount * 0.1 if input.member
+
+# METADATA
+# custom:
+#   default: "none"
+tier := "gold" if input.vip
+
+plain := 1 if input.x
+`
+	mod, err := ParseModule("test.rego", source, nil)
+	if err != nil {
+		t.Fatalf("ParseModule error: %v", err)
+	}
+
+	defaults := make(map[string]*ast.Term)
+	for _, r := range mod.Rules {
+		if r.Default {
+			defaults[r.Head.Ref().String()] = r.Head.Value
+		}
+	}
+	if v := defaults["discount"]; v == nil || !v.Equal(ast.IntNumberTerm(0)) {
+		t.Errorf("expected default discount := 0, got %v", v)
+	}
+	if v := defaults["tier"]; v == nil || !v.Equal(ast.StringTerm("none")) {
+		t.Errorf(`expected default tier := "none", got %v`, v)
+	}
+	if _, ok := defaults["plain"]; ok {
+		t.Error("unexpected default for unannotated rule 'plain'")
+	}
+}
+
+// TestAddDefaultValue_WinsOverDefaultFalse an annotated default is kept instead of default_false's false
+func TestAddDefaultValue_WinsOverDefaultFalse(t *testing.T) {
+	source := `package test
+import data.regobrick.default_false
+import data.regobrick.default_value
+
+# METADATA
+# custom:
+#   default: true
+open if { input.locked == false }
+
+allow if { input.x }
+`
+	mod, err := ParseModule("test.rego", source, nil)
+	if err != nil {
+		t.Fatalf("ParseModule error: %v", err)
+	}
+
+	defaults := make(map[string][]*ast.Term)
+	for _, r := range mod.Rules {
+		if r.Default {
+			ref := r.Head.Ref().String()
+			defaults[ref] = append(defaults[ref], r.Head.Value)
+		}
+	}
+	if got := defaults["open"]; len(got) != 1 || !got[0].Equal(ast.BooleanTerm(true)) {
+		t.Errorf("expected single default open := true, got %v", got)
+	}
+	if got := defaults["allow"]; len(got) != 1 || !got[0].Equal(ast.BooleanTerm(false)) {
+		t.Errorf("expected single default allow = false, got %v", got)
+	}
+}
+
+// TestAddDefaultValue_SameValueOnIncrementalRules incremental definitions with the
+// same annotated default produce one default rule
+func TestAddDefaultValue_SameValueOnIncrementalRules(t *testing.T) {
+	source := `package test
+import data.regobrick.default_value
+
+# METADATA
+# custom:
+#   default: 0
+rate := 1 if input.a
+
+# METADATA
+# custom:
+#   default: 0
+rate := 2 if input.b
+`
+	mod, err := ParseModule("test.rego", source, nil)
+	if err != nil {
+		t.Fatalf("ParseModule error: %v", err)
+	}
+	count := 0
+	for _, r := range mod.Rules {
+		if r.Default && r.Head.Ref().String() == "rate" {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Fatalf("expected 1 default for 'rate', got %d", count)
+	}
+}
+
+// TestAddDefaultValue_Errors cases that cannot honor the annotated default are rejected
+func TestAddDefaultValue_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		wantErr string
+	}{
+		{
+			name: "conflicting_values",
+			source: `package test
+import data.regobrick.default_value
+
+# METADATA
+# custom:
+#   default: 0
+rate := 1 if input.a
+
+# METADATA
+# custom:
+#   default: 1
+rate := 2 if input.b`,
+			wantErr: "conflicting annotated defaults",
+		},
+		{
+			name: "explicit_default",
+			source: `package test
+import data.regobrick.default_value
+
+default rate := 5
+
+# METADATA
+# custom:
+#   default: 0
+rate := 1 if input.a`,
+			wantErr: "already has an explicit default",
+		},
+		{
+			name: "function",
+			source: `package test
+import data.regobrick.default_value
+
+# METADATA
+# custom:
+#   default: 0
+double(x) := x * 2`,
+			wantErr: "function",
+		},
+		{
+			name: "partial_set",
+			source: `package test
+import data.regobrick.default_value
+
+# METADATA
+# custom:
+#   default: []
+deny contains msg if { msg := input.msg }`,
+			wantErr: "partial rule",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseModule("test.rego", tt.source, nil)
+			if err == nil {
+				t.Fatalf("expected error containing %q, got nil", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got: %v", tt.wantErr, err)
+			}
+			if !strings.Contains(err.Error(), "test.rego") {
+				t.Errorf("error should name the file, got: %v", err)
+			}
+		})
+	}
+}
+
+// TestAddDefaultValue_NoImport annotations are ignored without the feature import
+func TestAddDefaultValue_NoImport(t *testing.T) {
+	source := `package test
+
+# METADATA
+# custom:
+#   default: 0
+rate := 1 if input.a
+`
+	mod, err := ParseModule("test.rego", source, nil)
+	if err != nil {
+		t.Fatalf("ParseModule error: %v", err)
+	}
+	for _, r := range mod.Rules {
+		if r.Default {
+			t.Errorf("unexpected default rule without import: %v", r.Head.Ref())
+		}
+	}
+}
+
+// =============================================================================
+// default_false per-rule opt-out
+// =============================================================================
+
+// TestAddDefaultFalse_OptOutAnnotation a rule annotated with default_false: false stays undefined
+func TestAddDefaultFalse_OptOutAnnotation(t *testing.T) {
+	source := `package test
+import data.regobrick.default_false
+
+# METADATA
+# custom:
+#   regobrick:
+#     default_false: false
+applicable if { input.kind == "order" }
+
+# METADATA
+# custom:
+#   regobrick:
+#     default_false: true
+allow if { input.x }
+
+deny if { input.blocked }
+`
+	mod, err := ParseModule("test.rego", source, nil)
+	if err != nil {
+		t.Fatalf("ParseModule error: %v", err)
+	}
+
+	defaults := make(map[string]bool)
+	for _, r := range mod.Rules {
+		if r.Default {
+			defaults[r.Head.Ref().String()] = true
+		}
+	}
+	if defaults["applicable"] {
+		t.Error("expected no default for opted-out rule 'applicable'")
+	}
+	if !defaults["allow"] {
+		t.Error("expected default for 'allow' (default_false: true keeps the default)")
+	}
+	if !defaults["deny"] {
+		t.Error("expected default for unannotated rule 'deny'")
+	}
+}
+
+// TestAddDefaultFalse_OptOutAppliesToWholeRef opting out one definition opts out the rule
+func TestAddDefaultFalse_OptOutAppliesToWholeRef(t *testing.T) {
+	source := `package test
+import data.regobrick.default_false
+
+allow if { input.a }
+
+# METADATA
+# custom:
+#   regobrick:
+#     default_false: false
+allow if { input.b }
+`
+	mod, err := ParseModule("test.rego", source, nil)
+	if err != nil {
+		t.Fatalf("ParseModule error: %v", err)
+	}
+	for _, r := range mod.Rules {
+		if r.Default {
+			t.Errorf("unexpected default rule: %v", r.Head.Ref())
+		}
+	}
+}
+
+// TestAddDefaultFalse_InvalidOptOutAnnotation invalid regobrick annotations are errors
+func TestAddDefaultFalse_InvalidOptOutAnnotation(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		wantErr    string
+	}{
+		{
+			name: "non_boolean",
+			annotation: `# custom:
+#   regobrick:
+#     default_false: "no"`,
+			wantErr: "must be a boolean",
+		},
+		{
+			name: "not_a_map",
+			annotation: `# custom:
+#   regobrick: false`,
+			wantErr: "expected a map",
+		},
+		{
+			name: "unknown_setting",
+			annotation: `# custom:
+#   regobrick:
+#     default_flase: false`,
+			wantErr: "unknown setting",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := "package test\nimport data.regobrick.default_false\n\n# METADATA\n" +
+				tt.annotation + "\nallow if { input.x }\n"
+			_, err := ParseModule("test.rego", source, nil)
+			if err == nil {
+				t.Fatalf("expected error containing %q, got nil", tt.wantErr)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got: %v", tt.wantErr, err)
+			}
+			if !strings.Contains(err.Error(), "allow") {
+				t.Errorf("error should name the rule, got: %v", err)
+			}
+		})
+	}
+}
+
+// =============================================================================
+// Locations and generated marker of synthesized default rules
+// =============================================================================
+
+// TestDefaultRules_LocationAndGeneratedMarker checks that synthesized defaults
+// point at the file, row and column of the rule they default without taking over
+// its source text, and that they are marked as generated.
+func TestDefaultRules_LocationAndGeneratedMarker(t *testing.T) {
+	source := `package test
+import data.regobrick.default_false
+import data.regobrick.default_empty
+
+allow if { input.x }
+
+admins := {u | some u in input.users} if input.enabled
+`
+	mod, err := ParseModule("test.rego", source, nil)
+	if err != nil {
+		t.Fatalf("ParseModule error: %v", err)
+	}
+
+	wantRow := map[string]int{"allow": 5, "admins": 7}
+	wantFeature := map[string]string{"allow": featureDefaultFalse, "admins": featureDefaultEmpty}
+	seen := 0
+	for _, r := range mod.Rules {
+		if !r.Default {
+			if _, ok := GeneratedBy(r); ok {
+				t.Errorf("source rule %v must not be marked as generated", r.Head.Ref())
+			}
+			continue
+		}
+		seen++
+		ref := r.Head.Ref().String()
+		if r.Location == nil || r.Head.Location == nil || r.Head.Value.Location == nil {
+			t.Fatalf("default for %q has no location", ref)
+		}
+		for _, loc := range []*ast.Location{r.Location, r.Head.Location, r.Head.Value.Location} {
+			if loc.File != "test.rego" || loc.Row != wantRow[ref] || loc.Col != 1 {
+				t.Errorf("default for %q: expected location test.rego:%d:1, got %s:%d:%d",
+					ref, wantRow[ref], loc.File, loc.Row, loc.Col)
+			}
+		}
+		if len(r.Location.Text) != 0 || len(r.Head.Location.Text) != 0 {
+			t.Errorf("default for %q: expected no source text, got %q and %q", ref, r.Location.Text, r.Head.Location.Text)
+		}
+		if got, want := string(r.Head.Value.Location.Text), r.Head.Value.String(); got != want {
+			t.Errorf("default for %q: expected value text %q, got %q", ref, want, got)
+		}
+		feature, ok := GeneratedBy(r)
+		if !ok || feature != wantFeature[ref] {
+			t.Errorf("default for %q: expected generated by %q, got %q (ok=%v)", ref, wantFeature[ref], feature, ok)
+		}
+	}
+	if seen != 2 {
+		t.Fatalf("expected 2 synthesized defaults, got %d", seen)
+	}
+}
+
+// TestDefaultRules_RefNotShared checks that the default rule holds a copy of the
+// ref of the source rule rather than sharing its terms.
+func TestDefaultRules_RefNotShared(t *testing.T) {
+	source := `package test
+import data.regobrick.default_false
+
+limits.enabled if { input.x }
+`
+	mod, err := ParseModule("test.rego", source, nil)
+	if err != nil {
+		t.Fatalf("ParseModule error: %v", err)
+	}
+	if len(mod.Rules) != 2 {
+		t.Fatalf("expected 2 rules, got %d", len(mod.Rules))
+	}
+	orig, def := mod.Rules[0].Head.Ref(), mod.Rules[1].Head.Ref()
+	if !orig.Equal(def) {
+		t.Fatalf("expected equal refs, got %v and %v", orig, def)
+	}
+	if orig[0] == def[0] {
+		t.Error("expected the default rule to hold a copy of the ref, not the same terms")
+	}
+}
+
+// TestGeneratedAnnotation_RejectedInSource checks that the generated marker is
+// rejected on source rules when a feature is enabled, so GeneratedBy never
+// reports a hand-written rule.
+func TestGeneratedAnnotation_RejectedInSource(t *testing.T) {
+	for _, imports := range []string{"import data.regobrick.default_false\n", "import data.regobrick.default_empty\n"} {
+		source := "package test\n" + imports + `
+# METADATA
+# custom:
+#   regobrick:
+#     generated: default_false
+allow if { input.x }
+`
+		_, err := ParseModule("test.rego", source, nil)
+		if err == nil || !strings.Contains(err.Error(), `"generated" is reserved`) {
+			t.Errorf("%q: expected reserved generated error, got: %v", imports, err)
+		}
+	}
+}
+
+// TestRuleSettings_ValidatedWithoutDefaultFalse checks that "custom.regobrick"
+// settings are validated whatever features the module enables.
+func TestRuleSettings_ValidatedWithoutDefaultFalse(t *testing.T) {
+	const annotated = `
+# METADATA
+# custom:
+#   regobrick:
+#     defualt_false: false
+allow if { input.x }
+`
+	for _, imports := range []string{"import data.regobrick.default_empty\n", "import data.regobrick.default_value\n"} {
+		_, err := ParseModule("test.rego", "package test\n"+imports+annotated, nil)
+		if err == nil || !strings.Contains(err.Error(), `unknown setting "defualt_false"`) {
+			t.Errorf("%q: expected unknown setting error, got: %v", imports, err)
+		}
+	}
+
+	_, err := ParseModuleWithOptions("test.rego", "package test\n"+annotated, ParseOptions{Features: []string{featureDefaultEmpty}})
+	if err == nil || !strings.Contains(err.Error(), "unknown setting") {
+		t.Errorf("host-enabled feature: expected unknown setting error, got: %v", err)
+	}
+
+	// A valid setting is accepted even when default_false is not enabled.
+	valid := strings.Replace(annotated, "defualt_false", "default_false", 1)
+	if _, err := ParseModule("test.rego", "package test\nimport data.regobrick.default_empty\n"+valid, nil); err != nil {
+		t.Errorf("expected a valid setting to be accepted, got: %v", err)
+	}
+}
+
+// TestRuleSettings_PlainModuleNotValidated checks that a module enabling no
+// feature keeps its "custom.regobrick" annotations as they are, so plain OPA
+// modules that happen to use the key still parse.
+func TestRuleSettings_PlainModuleNotValidated(t *testing.T) {
+	source := `package test
+
+# METADATA
+# custom:
+#   regobrick:
+#     generated: elsewhere
+#     owner: billing
+allow if { input.x }
+`
+	mod, err := ParseModule("test.rego", source, nil)
+	if err != nil {
+		t.Fatalf("ParseModule error: %v", err)
+	}
+	if len(mod.Rules) != 1 {
+		t.Fatalf("expected the module to be left unchanged, got %d rules", len(mod.Rules))
+	}
+	custom := mod.Rules[0].Annotations[0].Custom[regobrickAnnotationKey].(map[string]any)
+	if custom["owner"] != "billing" {
+		t.Errorf("expected the annotation to be kept, got %v", custom)
+	}
+}
+
+// ===== Rego v0 syntax =====
+
+func TestParseModuleWithOptions_V0(t *testing.T) {
+	source := `package test
+import data.regobrick.default_false
+
+allow { helper.is_admin(input.user) }
+
+deny[msg] { input.blocked; msg := "blocked" }
+`
+	mod, err := ParseModuleWithOptions("test.rego", source, ParseOptions{
+		Imports:     []string{"data.helper"},
+		RegoVersion: ast.RegoV0,
+	})
+	if err != nil {
+		t.Fatalf("ParseModuleWithOptions error: %v", err)
+	}
+	if mod.RegoVersion() != ast.RegoV0 {
+		t.Errorf("expected a v0 module, got %v", mod.RegoVersion())
+	}
+
+	defaults := 0
+	for _, r := range mod.Rules {
+		if !r.Default {
+			continue
+		}
+		defaults++
+		if r.Head.Ref().String() != "allow" {
+			t.Errorf("unexpected default for %v", r.Head.Ref())
+		}
+		if r.Head.Assign {
+			t.Errorf("expected v0 syntax \"default allow = false\", got %v", r)
+		}
+	}
+	if defaults != 1 {
+		t.Fatalf("expected 1 default rule, got %d", defaults)
+	}
+
+	hasHelper := false
+	for _, imp := range mod.Imports {
+		if imp.Path.String() == "data.helper" {
+			hasHelper = true
+		}
+	}
+	if !hasHelper {
+		t.Errorf("expected injected import data.helper, got %v", mod.Imports)
+	}
+
+	// The same source is rejected by the default v1 parser.
+	if _, err := ParseModule("test.rego", source, nil); err == nil {
+		t.Error("expected v1 parse error for v0 source, got nil")
+	}
+}
+
+func TestParseModuleWithOptions_V1UsesAssign(t *testing.T) {
+	source := `package test
+import data.regobrick.default_false
+
+allow if input.x
+`
+	for _, version := range []ast.RegoVersion{ast.RegoUndefined, ast.RegoV1} {
+		mod, err := ParseModuleWithOptions("test.rego", source, ParseOptions{RegoVersion: version})
+		if err != nil {
+			t.Fatalf("ParseModuleWithOptions(%v) error: %v", version, err)
+		}
+		if len(mod.Rules) != 2 || !mod.Rules[1].Default || !mod.Rules[1].Head.Assign {
+			t.Errorf("%v: expected \"default allow := false\", got %v", version, mod.Rules)
+		}
+	}
+}
+
+// ===== Features enabled through options =====
+
+func TestParseModuleWithOptions_Features(t *testing.T) {
+	source := `package test
+
+allow if input.x
+`
+	mod, report, err := parseModule("test.rego", source, ParseOptions{Features: []string{featureDefaultFalse}})
+	if err != nil {
+		t.Fatalf("parseModule error: %v", err)
+	}
+	if len(mod.Rules) != 2 || !mod.Rules[1].Default {
+		t.Fatalf("expected default_false to be applied without a marker, got %v", mod.Rules)
+	}
+	if strings.Join(report.Features, ",") != featureDefaultFalse {
+		t.Errorf("expected report to list default_false, got %v", report.Features)
+	}
+
+	// A marker for the same feature is not applied twice.
+	withMarker := "package test\nimport data.regobrick.default_false\n\nallow if input.x\n"
+	mod, err = ParseModuleWithOptions("test.rego", withMarker, ParseOptions{Features: []string{featureDefaultFalse}})
+	if err != nil {
+		t.Fatalf("ParseModuleWithOptions error: %v", err)
+	}
+	if len(mod.Rules) != 2 {
+		t.Errorf("expected a single default rule, got %v", mod.Rules)
+	}
+
+	_, err = ParseModuleWithOptions("test.rego", source, ParseOptions{Features: []string{"default_flase"}})
+	if err == nil || !strings.Contains(err.Error(), `unknown feature "default_flase"`) {
+		t.Errorf("expected unknown feature error, got: %v", err)
+	}
+}
+
+func TestParseModuleWithOptions_ForbidMarkers(t *testing.T) {
+	source := `package test
+import data.regobrick.default_false
+
+allow if input.x
+`
+	_, err := ParseModuleWithOptions("tenant.rego", source, ParseOptions{
+		Features:      []string{featureDefaultFalse},
+		ForbidMarkers: true,
+	})
+	if err == nil || !strings.Contains(err.Error(), "data.regobrick.default_false") || !strings.Contains(err.Error(), "tenant.rego:2") {
+		t.Fatalf("expected marker error with location, got: %v", err)
+	}
+
+	// Markers injected by the host through Imports are still allowed.
+	mod, err := ParseModuleWithOptions("tenant.rego", "package test\n\nallow if input.x\n", ParseOptions{
+		Imports:       []string{"data.regobrick.default_false"},
+		ForbidMarkers: true,
+	})
+	if err != nil {
+		t.Fatalf("ParseModuleWithOptions error: %v", err)
+	}
+	if len(mod.Rules) != 2 {
+		t.Errorf("expected the injected feature to apply, got %v", mod.Rules)
+	}
+}
+
+// ===== default_false_functions =====
+
+func TestAddDefaultFalseFunctions(t *testing.T) {
+	source := `package test
+import data.regobrick.default_false_functions
+
+is_owner(user, res) if { res.owner == user }
+
+is_owner(user, res) if { user == "root"; res != null }
+
+price(x) := 1 if { x == "a" }
+
+# METADATA
+# custom:
+#   regobrick:
+#     default_false: false
+maybe(x) if { x > 1 }
+
+has_default(x) if { x > 1 }
+
+default has_default(_) := true
+
+mixed(x) if { x > 1 }
+
+mixed(x) := "big" if { x > 100 }
+
+allow if { input.x }
+`
+	mod, err := ParseModule("test.rego", source, nil)
+	if err != nil {
+		t.Fatalf("ParseModule error: %v", err)
+	}
+
+	var synthesized []*ast.Rule
+	for _, r := range mod.Rules {
+		if _, ok := GeneratedBy(r); ok {
+			synthesized = append(synthesized, r)
+		}
+	}
+	if len(synthesized) != 1 {
+		t.Fatalf("expected a single default for is_owner, got %v", synthesized)
+	}
+	r := synthesized[0]
+	if r.Head.Ref().String() != "is_owner" || len(r.Head.Args) != 2 {
+		t.Fatalf("expected default is_owner/2, got %v", r)
+	}
+	if v, ok := r.Head.Value.Value.(ast.Boolean); !ok || bool(v) {
+		t.Errorf("expected false value, got %v", r.Head.Value)
+	}
+	for _, arg := range r.Head.Args {
+		if v, ok := arg.Value.(ast.Var); !ok || !v.IsWildcard() {
+			t.Errorf("expected wildcard args, got %v", r.Head.Args)
+		}
+	}
+	if r.Location == nil || r.Location.Row != 4 {
+		t.Errorf("expected location of the first definition (row 4), got %v", r.Location)
+	}
+}
+
+func TestAddDefaultFalseFunctions_SeparateFromDefaultFalse(t *testing.T) {
+	source := `package test
+import data.regobrick.default_false
+
+is_owner(user, res) if { res.owner == user }
+
+allow if { input.x }
+`
+	mod, err := ParseModule("test.rego", source, nil)
+	if err != nil {
+		t.Fatalf("ParseModule error: %v", err)
+	}
+	for _, r := range mod.Rules {
+		if r.Default && len(r.Head.Args) > 0 {
+			t.Errorf("default_false alone must not default functions, got %v", r)
+		}
+	}
+}
+
+func TestFormatModule_DefaultFalseFunctions(t *testing.T) {
+	source := `package test
+import data.regobrick.default_false_functions
+
+is_owner(user, res) if { res.owner == user }
+`
+	out, err := FormatModule("test.rego", source, nil)
+	if err != nil {
+		t.Fatalf("FormatModule error: %v", err)
+	}
+	if !strings.Contains(string(out), "default is_owner(_, _) := false") {
+		t.Errorf("expected arity-matching default function, got:\n%s", out)
+	}
+}
@@ -0,0 +1,135 @@
+package module
+
+import (
+	"fmt"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+)
+
+// featureCollectReasons is the regobrick feature that turns the messages of deny
+// rules into structured reasons.
+const featureCollectReasons = "collect_reasons"
+
+const (
+	// reasonsSourceRule is the partial set rule collect_reasons reads.
+	reasonsSourceRule = "deny"
+	// reasonsRule is the rule collect_reasons adds to aggregate the reasons.
+	reasonsRule = "reasons"
+)
+
+// collectReasons adds a "reasons" partial set rule next to the "deny contains
+// msg if ..." rules of mod. For each deny definition it adds a reasons
+// definition with the same body, whose element is an object describing where
+// the message came from:
+//
+//	{
+//	    "msg": msg,
+//	    "rule": "data.app.billing.deny",
+//	    "title": "Invoices need an approver", # METADATA title, or null
+//	    "location": {"file": "billing.rego", "row": 12},
+//	}
+//
+// so the reasons of a package can be queried under a name of their own. The
+// title comes from the deny rule's METADATA annotations and the location from
+// its definition, which tells apart several incremental definitions of deny.
+// The deny rules themselves are left untouched and still produce their
+// messages, so existing consumers of deny keep working.
+//
+// Modules without a partial set deny rule are left unchanged. A module that
+// already defines a "reasons" rule is rejected, and so is a ref-head deny rule
+// such as "deny.billing contains msg if ...": its messages do not belong to
+// the deny set, so collecting them would misreport the rule.
+func collectReasons(mod *ast.Module) error {
+	for _, r := range mod.Rules {
+		if r.Head.Ref().Equal(ast.Ref{ast.VarTerm(reasonsRule)}) {
+			return fmt.Errorf(
+				"regobrick: %s: module already defines rule %q at %v",
+				featureCollectReasons, reasonsRule, r.Location,
+			)
+		}
+	}
+
+	denyRef := ast.Ref{ast.VarTerm(reasonsSourceRule)}
+	var reasons []*ast.Rule
+	for _, r := range mod.Rules {
+		ref := r.Head.Ref()
+		if len(ref) > 1 && ref[0].Equal(denyRef[0]) {
+			return fmt.Errorf(
+				"regobrick: %s: ref-head rule %q at %v is not supported, define deny as a partial set rule",
+				featureCollectReasons, ref.String(), r.Location,
+			)
+		}
+		if r.Default || len(r.Head.Args) > 0 || r.Head.Key == nil || r.Head.Value != nil || !ref.Equal(denyRef) {
+			continue
+		}
+		reasons = append(reasons, newReasonsRule(mod, r))
+	}
+	mod.Rules = append(mod.Rules, reasons...)
+	return nil
+}
+
+// reasonTerm returns the object term a reasons definition emits for the
+// element of the deny definition r.
+func reasonTerm(mod *ast.Module, r *ast.Rule) *ast.Term {
+	msg := r.Head.Key.Copy()
+	loc := msg.Location
+	title := ast.NullTerm()
+	for _, a := range r.Annotations {
+		if a.Title != "" {
+			title = ast.StringTerm(a.Title)
+			break
+		}
+	}
+	location := ast.NullTerm()
+	if r.Location != nil {
+		location = ast.ObjectTerm(
+			ast.Item(ast.StringTerm("file"), ast.StringTerm(r.Location.File)),
+			ast.Item(ast.StringTerm("row"), ast.IntNumberTerm(r.Location.Row)),
+		)
+	}
+	rule := ast.StringTerm(mod.Package.Path.Extend(r.Head.Ref()).String())
+
+	// The synthesized terms are located at msg; msg keeps its own location.
+	items := [][2]*ast.Term{
+		{ast.StringTerm("msg"), msg},
+		{ast.StringTerm("rule"), rule},
+		{ast.StringTerm("title"), title},
+		{ast.StringTerm("location"), location},
+	}
+	for _, item := range items {
+		locateTerm(item[0], loc)
+		if item[1] != msg {
+			locateTerm(item[1], loc)
+		}
+	}
+	obj := ast.ObjectTerm(items...)
+	obj.Location = synthesizedLocation(loc, []byte(obj.String()))
+	return obj
+}
+
+// newReasonsRule builds "reasons contains {...} if <body>" from the deny
+// definition orig, with a copy of its body, at its location, and marks it as
+// generated; see GeneratedBy and synthesizedLocation. The formatter renders it
+// in the syntax of the module's version.
+func newReasonsRule(mod *ast.Module, orig *ast.Rule) *ast.Rule {
+	loc := synthesizedLocation(orig.Location, nil)
+	name := ast.VarTerm(reasonsRule)
+	locateTerm(name, orig.Location)
+	return &ast.Rule{
+		Head: &ast.Head{
+			Name:      ast.Var(reasonsRule),
+			Reference: ast.Ref{name},
+			Key:       reasonTerm(mod, orig),
+			Location:  loc,
+		},
+		Body:     orig.Body.Copy(),
+		Location: loc,
+		Annotations: []*ast.Annotations{{
+			Scope: "rule",
+			Custom: map[string]any{
+				regobrickAnnotationKey: map[string]any{generatedAnnotationKey: featureCollectReasons},
+			},
+			Location: loc,
+		}},
+	}
+}
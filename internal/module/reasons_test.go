@@ -0,0 +1,171 @@
+package module
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/open-policy-agent/opa/v1/rego"
+)
+
+const reasonsPolicy = `package app.billing
+import data.regobrick.collect_reasons
+
+# METADATA
+# title: Invoices need an approver
+deny contains msg if {
+	not input.approver
+	msg := sprintf("invoice %s has no approver", [input.id])
+}
+
+deny contains "amount must be positive" if input.amount <= 0
+
+allow if count(deny) == 0
+`
+
+func TestCollectReasons(t *testing.T) {
+	ctx := context.Background()
+	mod, err := ParseModule("billing.rego", reasonsPolicy, nil)
+	if err != nil {
+		t.Fatalf("ParseModule error: %v", err)
+	}
+	for _, r := range mod.Rules[len(mod.Rules)-2:] {
+		if feature, ok := GeneratedBy(r); !ok || feature != featureCollectReasons {
+			t.Errorf("expected a generated reasons rule, got %v", r)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		// deny is left untouched: its consumers still get the messages.
+		{"data.app.billing.deny", `["amount must be positive","invoice 42 has no approver"]`},
+		{"count(data.app.billing.deny)", `2`},
+		{"data.app.billing.reasons", `[` +
+			`{"location":{"file":"billing.rego","row":6},"msg":"invoice 42 has no approver","rule":"data.app.billing.deny","title":"Invoices need an approver"},` +
+			`{"location":{"file":"billing.rego","row":11},"msg":"amount must be positive","rule":"data.app.billing.deny","title":null}` +
+			`]`},
+	}
+	for _, tt := range tests {
+		rs, err := rego.New(rego.ParsedModule(mod.Copy()), rego.Query(tt.query),
+			rego.Input(map[string]any{"id": "42", "amount": 0})).Eval(ctx)
+		if err != nil {
+			t.Fatalf("%s: Eval error: %v", tt.query, err)
+		}
+		got, err := json.Marshal(rs[0].Expressions[0].Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestCollectReasons_Format(t *testing.T) {
+	for _, tc := range []struct {
+		version ast.RegoVersion
+		source  string
+		want    string
+	}{
+		{ast.RegoV1, reasonsPolicy, "deny contains msg if {\n\tnot input.approver\n"},
+		{ast.RegoV1, reasonsPolicy, `reasons contains {"location": {"file": "billing.rego", "row": 11}, "msg": "amount must be positive", "rule": "data.app.billing.deny", "title": null} if input.amount <= 0`},
+		{ast.RegoV0, "package app\nimport data.regobrick.collect_reasons\n\ndeny[msg] { msg := \"no\" }\n", "deny[msg] {\n\tmsg := \"no\"\n}"},
+		{ast.RegoV0, "package app\nimport data.regobrick.collect_reasons\n\ndeny[msg] { msg := \"no\" }\n", "reasons[{\"location\": {\"file\": \"billing.rego\", \"row\": 4}, \"msg\": msg, \"rule\": \"data.app.deny\", \"title\": null}] {\n\tmsg := \"no\"\n}"},
+	} {
+		out, err := FormatModuleWithOptions("billing.rego", tc.source, ParseOptions{RegoVersion: tc.version})
+		if err != nil {
+			t.Fatalf("FormatModuleWithOptions(%v) error: %v", tc.version, err)
+		}
+		if !strings.Contains(string(out), tc.want) {
+			t.Errorf("%v: expected %q in output, got:\n%s", tc.version, tc.want, out)
+		}
+		if _, err := ast.ParseModuleWithOpts("rendered.rego", string(out), ast.ParserOptions{RegoVersion: tc.version}); err != nil {
+			t.Errorf("%v: rendered output does not parse: %v\n%s", tc.version, err, out)
+		}
+	}
+}
+
+func TestCollectReasons_FormatStringLiteralDeny(t *testing.T) {
+	source := `package app
+import data.regobrick.collect_reasons
+
+deny contains "amount must be positive" if input.amount <= 0
+`
+	out, err := FormatModule("app.rego", source, nil)
+	if err != nil {
+		t.Fatalf("FormatModule error: %v", err)
+	}
+	mod, err := ast.ParseModule("rendered.rego", string(out))
+	if err != nil {
+		t.Fatalf("rendered output does not parse: %v\n%s", err, out)
+	}
+	want := ast.MustParseTerm(`{
+		"msg": "amount must be positive",
+		"rule": "data.app.deny",
+		"title": null,
+		"location": {"file": "app.rego", "row": 4},
+	}`)
+	if len(mod.Rules) != 2 || !mod.Rules[0].Head.Key.Equal(ast.StringTerm("amount must be positive")) ||
+		!mod.Rules[1].Head.Key.Equal(want) {
+		t.Fatalf("expected deny and the reasons element %v to survive rendering, got:\n%s", want, out)
+	}
+}
+
+// TestCollectReasons_FormatWithComments checks that the copied deny body renders
+// next to the comments of the original without repeating them.
+func TestCollectReasons_FormatWithComments(t *testing.T) {
+	source := `package app
+import data.regobrick.collect_reasons
+
+deny contains msg if {
+	# inside
+	input.x # trailing
+	msg := "x"
+}
+`
+	out, err := FormatModule("app.rego", source, nil)
+	if err != nil {
+		t.Fatalf("FormatModule error: %v", err)
+	}
+	for _, comment := range []string{"# inside", "# trailing"} {
+		if n := strings.Count(string(out), comment); n != 1 {
+			t.Errorf("expected %q once, got %d times:\n%s", comment, n, out)
+		}
+	}
+	if _, err := ast.ParseModule("rendered.rego", string(out)); err != nil {
+		t.Errorf("rendered output does not parse: %v\n%s", err, out)
+	}
+}
+
+func TestCollectReasons_Errors(t *testing.T) {
+	source := `package app
+import data.regobrick.collect_reasons
+
+deny contains "no" if input.x
+
+reasons := {"custom"}
+`
+	_, err := ParseModule("app.rego", source, nil)
+	if err == nil || !strings.Contains(err.Error(), `already defines rule "reasons" at app.rego:6`) {
+		t.Errorf("expected conflict with existing reasons rule, got: %v", err)
+	}
+
+	// Ref-head deny rules are rejected rather than misreported.
+	_, err = ParseModule("app.rego", "package app\nimport data.regobrick.collect_reasons\n\ndeny.billing contains \"no\" if input.x\n", nil)
+	if err == nil || !strings.Contains(err.Error(), `ref-head rule "deny.billing" at app.rego:4 is not supported`) {
+		t.Errorf("expected a ref-head deny error, got: %v", err)
+	}
+
+	// Without a partial set deny rule the module is left unchanged.
+	mod, err := ParseModule("app.rego", "package app\nimport data.regobrick.collect_reasons\n\ndeny := false\n", nil)
+	if err != nil {
+		t.Fatalf("ParseModule error: %v", err)
+	}
+	if len(mod.Rules) != 1 {
+		t.Errorf("expected no rule to be added, got %v", mod.Rules)
+	}
+}
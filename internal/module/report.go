@@ -0,0 +1,114 @@
+package module
+
+import "github.com/open-policy-agent/opa/v1/ast"
+
+// Report describes what ParseModuleWithReport changed in a module. It is exposed
+// publicly as regobrick.Report. Every list keeps the order in which the change
+// was made, so a Report can be compared directly in tests and logged as JSON.
+type Report struct {
+	// Filename is the module's name as passed to ParseModuleWithReport.
+	Filename string `json:"filename"`
+	// Features lists the applied features in application order, including
+	// features enabled through FeatureRequires.
+	Features []string `json:"features,omitempty"`
+	// DefaultRules lists the default rules synthesized by the applied features.
+	DefaultRules []DefaultRule `json:"default_rules,omitempty"`
+	// RuleChanges lists every rule an applied feature added, rewrote or
+	// removed, including the default rules above, the rules synthesized by
+	// collect_reasons and the operators rewritten by decimal_arithmetic.
+	RuleChanges []RuleChange `json:"rule_changes,omitempty"`
+	// InjectedImports lists the injected import paths that were added to the module.
+	InjectedImports []string `json:"injected_imports,omitempty"`
+	// DeduplicatedImports lists the injected import paths that were not added
+	// because the module already imports them.
+	DeduplicatedImports []string `json:"deduplicated_imports,omitempty"`
+	// RemovedMarkers lists the "data.regobrick.*" marker imports stripped from
+	// the module.
+	RemovedMarkers []string `json:"removed_markers,omitempty"`
+}
+
+// DefaultRule describes one synthesized "default <Ref> := <Value>" rule.
+type DefaultRule struct {
+	// Feature is the feature that synthesized the rule, e.g. "default_false".
+	Feature string `json:"feature"`
+	// Ref is the rule reference, e.g. "allow" or "limits.by_region".
+	Ref string `json:"ref"`
+	// Value is the default value in Rego syntax, e.g. "false" or "set()".
+	Value string `json:"value"`
+}
+
+// Kinds of RuleChange.
+const (
+	// RuleAdded marks a rule synthesized by a feature.
+	RuleAdded = "added"
+	// RuleRewritten marks a source rule whose head or body a feature changed.
+	RuleRewritten = "rewritten"
+	// RuleRemoved marks a rule a feature dropped from the module.
+	RuleRemoved = "removed"
+)
+
+// RuleChange describes one rule changed by a feature.
+type RuleChange struct {
+	// Feature is the feature that made the change, e.g. "decimal_arithmetic".
+	Feature string `json:"feature"`
+	// Change is RuleAdded, RuleRewritten or RuleRemoved.
+	Change string `json:"change"`
+	// Ref is the rule reference, e.g. "allow" or "limits.by_region".
+	Ref string `json:"ref"`
+	// Row is the source line of the rule, or of the rule a synthesized rule was
+	// derived from; 0 if unknown.
+	Row int `json:"row,omitempty"`
+}
+
+// ruleSnapshot records the rules of a module before a feature runs, so that
+// diff can report what the feature changed whether it appends rules or
+// rewrites them in place.
+type ruleSnapshot struct {
+	rules  []*ast.Rule
+	copies map[*ast.Rule]*ast.Rule
+}
+
+func snapshotRules(mod *ast.Module) ruleSnapshot {
+	s := ruleSnapshot{
+		rules:  append([]*ast.Rule(nil), mod.Rules...),
+		copies: make(map[*ast.Rule]*ast.Rule, len(mod.Rules)),
+	}
+	for _, r := range mod.Rules {
+		s.copies[r] = r.Copy()
+	}
+	return s
+}
+
+// diff returns the changes feature made to the rules of mod since s was taken,
+// in module order followed by the removed rules.
+func (s ruleSnapshot) diff(feature string, mod *ast.Module) []RuleChange {
+	var changes []RuleChange
+	kept := make(map[*ast.Rule]bool, len(mod.Rules))
+	for _, r := range mod.Rules {
+		kept[r] = true
+		orig, ok := s.copies[r]
+		switch {
+		case !ok:
+			changes = append(changes, newRuleChange(feature, RuleAdded, r))
+		case !orig.Equal(r):
+			changes = append(changes, newRuleChange(feature, RuleRewritten, r))
+		}
+	}
+	for _, r := range s.rules {
+		if !kept[r] {
+			changes = append(changes, newRuleChange(feature, RuleRemoved, s.copies[r]))
+		}
+	}
+	return changes
+}
+
+func newRuleChange(feature, change string, r *ast.Rule) RuleChange {
+	c := RuleChange{Feature: feature, Change: change}
+	if r.Head != nil {
+		c.Ref = r.Head.Ref().String()
+	}
+	if r.Location != nil {
+		c.Row = r.Location.Row
+	}
+	return c
+}
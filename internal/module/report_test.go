@@ -0,0 +1,138 @@
+package module
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+)
+
+func TestParseModuleWithReport(t *testing.T) {
+	source := `package test
+import data.regobrick.default_false
+import data.regobrick.default_empty
+import data.helper
+
+allow if { helper.ok }
+admins := {u | some u in input.users} if input.enabled
+limits.by_region := {"eu": 10} if input.enabled
+`
+	_, report, err := ParseModuleWithReport("test.rego", source, []string{"data.helper", "data.lib.money", "", "data.lib.money"})
+	if err != nil {
+		t.Fatalf("ParseModuleWithReport error: %v", err)
+	}
+
+	want := &Report{
+		Filename: "test.rego",
+		Features: []string{featureDefaultEmpty, featureDefaultFalse},
+		DefaultRules: []DefaultRule{
+			{Feature: featureDefaultEmpty, Ref: "admins", Value: "set()"},
+			{Feature: featureDefaultEmpty, Ref: "limits.by_region", Value: "{}"},
+			{Feature: featureDefaultFalse, Ref: "allow", Value: "false"},
+		},
+		RuleChanges: []RuleChange{
+			{Feature: featureDefaultEmpty, Change: RuleAdded, Ref: "admins", Row: 7},
+			{Feature: featureDefaultEmpty, Change: RuleAdded, Ref: "limits.by_region", Row: 8},
+			{Feature: featureDefaultFalse, Change: RuleAdded, Ref: "allow", Row: 6},
+		},
+		InjectedImports:     []string{"data.lib.money"},
+		DeduplicatedImports: []string{"data.helper", "data.lib.money"},
+		RemovedMarkers:      []string{"data.regobrick.default_false", "data.regobrick.default_empty"},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Fatalf("unexpected report:\n got: %+v\nwant: %+v", report, want)
+	}
+}
+
+// TestParseModuleWithReport_RuleChanges checks that features which rewrite
+// rules in place or synthesize non-default rules are reported too.
+func TestParseModuleWithReport_RuleChanges(t *testing.T) {
+	source := `package test
+import data.regobrick.decimal_arithmetic
+import data.regobrick.collect_reasons
+
+total := input.a + input.b
+name := input.name
+
+deny contains "no name" if not input.name
+`
+	_, report, err := ParseModuleWithReport("test.rego", source, nil)
+	if err != nil {
+		t.Fatalf("ParseModuleWithReport error: %v", err)
+	}
+	want := []RuleChange{
+		{Feature: featureCollectReasons, Change: RuleAdded, Ref: "reasons", Row: 8},
+		{Feature: featureDecimalArithmetic, Change: RuleRewritten, Ref: "total", Row: 5},
+	}
+	if !reflect.DeepEqual(report.RuleChanges, want) {
+		t.Fatalf("unexpected rule changes:\n got: %+v\nwant: %+v", report.RuleChanges, want)
+	}
+	if len(report.DefaultRules) != 0 {
+		t.Errorf("expected no default rules, got %+v", report.DefaultRules)
+	}
+}
+
+// TestRuleSnapshot_Removed checks that a rule dropped by a feature is reported.
+func TestRuleSnapshot_Removed(t *testing.T) {
+	mod := ast.MustParseModule("package test\nallow if input.x\ndeny if input.y\n")
+	snapshot := snapshotRules(mod)
+	mod.Rules = mod.Rules[:1]
+	want := []RuleChange{{Feature: "prune", Change: RuleRemoved, Ref: "deny", Row: 3}}
+	if got := snapshot.diff("prune", mod); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestParseModuleWithReport_PlainModule(t *testing.T) {
+	_, report, err := ParseModuleWithReport("plain.rego", "package test\nallow if input.x\n", nil)
+	if err != nil {
+		t.Fatalf("ParseModuleWithReport error: %v", err)
+	}
+	if !reflect.DeepEqual(report, &Report{Filename: "plain.rego"}) {
+		t.Fatalf("expected an empty report, got %+v", report)
+	}
+}
+
+func TestParseModuleWithReport_ErrorHasNoReport(t *testing.T) {
+	_, report, err := ParseModuleWithReport("test.rego", "package test\nimport data.regobrick.default_flase\n", nil)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if report != nil {
+		t.Fatalf("expected nil report on error, got %+v", report)
+	}
+}
+
+func TestParseModuleWithOptionsReport(t *testing.T) {
+	source := `package test
+
+allow { input.x }
+`
+	mod, report, err := ParseModuleWithOptionsReport("legacy.rego", source, ParseOptions{
+		Imports:     []string{"data.lib.money"},
+		RegoVersion: ast.RegoV0,
+		Features:    []string{featureDefaultFalse},
+	})
+	if err != nil {
+		t.Fatalf("ParseModuleWithOptionsReport error: %v", err)
+	}
+	want := &Report{
+		Filename:        "legacy.rego",
+		Features:        []string{featureDefaultFalse},
+		DefaultRules:    []DefaultRule{{Feature: featureDefaultFalse, Ref: "allow", Value: "false"}},
+		RuleChanges:     []RuleChange{{Feature: featureDefaultFalse, Change: RuleAdded, Ref: "allow", Row: 3}},
+		InjectedImports: []string{"data.lib.money"},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Fatalf("unexpected report:\n got: %+v\nwant: %+v", report, want)
+	}
+	if mod.RegoVersion() != ast.RegoV0 {
+		t.Errorf("expected a v0 module, got %v", mod.RegoVersion())
+	}
+
+	_, report, err = ParseModuleWithOptionsReport("tenant.rego", "package test\nimport data.regobrick.default_false\n",
+		ParseOptions{ForbidMarkers: true})
+	if err == nil || report != nil {
+		t.Fatalf("expected a forbidden marker error without report, got %+v, %v", report, err)
+	}
+}
@@ -0,0 +1,255 @@
+package module
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/open-policy-agent/opa/v1/rego"
+)
+
+// PolicyStoreOptions configures a PolicyStore. It is exposed publicly as
+// regobrick.PolicyStoreOptions.
+type PolicyStoreOptions struct {
+	// Pattern selects the ".rego" files of the fs.FS to load, as in ModulesFS
+	// ("" for all files).
+	Pattern string
+	// Rules selects the imports of each module by package or path glob, as in
+	// ModulesFS.
+	Rules []ImportRule
+	// Features lists features to apply to every module; see ParseOptions.
+	Features []string
+	// ForbidMarkers rejects modules containing "data.regobrick.*" marker
+	// imports; see ParseOptions.
+	ForbidMarkers bool
+	// BigDecimalLiterals checks number literals against BigDecimalBackend; see
+	// ParseOptions.
+	BigDecimalLiterals bool
+	// RegoVersion selects the Rego syntax of every module; see ParseOptions.
+	RegoVersion ast.RegoVersion
+	// Queries maps a name to the query prepared under it, e.g.
+	// "allow": "data.app.allow". At least one query is required.
+	Queries map[string]string
+	// Options are applied to the rego.Rego of every prepared query, before the
+	// modules and the query, e.g. rego.Store or rego.Capabilities. They are
+	// applied again on each reload.
+	Options []func(*rego.Rego)
+	// OnReload, if set, is called after every reload that found a change, with
+	// the new generation or, if the reload failed, the still-current generation
+	// and the error. It is called synchronously by Reload, including for the
+	// first generation loaded by NewPolicyStore. It is not called when that
+	// initial load fails: there is no generation to pass, and NewPolicyStore
+	// returns the error instead.
+	OnReload func(gen *PolicyGeneration, err error)
+}
+
+// PolicyGeneration is an immutable set of queries prepared from one snapshot of
+// the policy files. It is exposed publicly as regobrick.PolicyGeneration.
+type PolicyGeneration struct {
+	// ID numbers the generations of a PolicyStore, starting at 1.
+	ID uint64
+	// LoadedAt is the time the generation was prepared.
+	LoadedAt time.Time
+	// Files lists the loaded module paths in lexical order.
+	Files []string
+
+	queries map[string]rego.PreparedEvalQuery
+}
+
+// Query returns the query prepared under name.
+func (g *PolicyGeneration) Query(name string) (rego.PreparedEvalQuery, bool) {
+	q, ok := g.queries[name]
+	return q, ok
+}
+
+// Eval evaluates the query prepared under name.
+func (g *PolicyGeneration) Eval(ctx context.Context, name string, opts ...rego.EvalOption) (rego.ResultSet, error) {
+	q, ok := g.queries[name]
+	if !ok {
+		return nil, fmt.Errorf("regobrick: unknown query %q", name)
+	}
+	return q.Eval(ctx, opts...)
+}
+
+// PolicyStore holds the queries prepared from the ".rego" files of an fs.FS and
+// swaps them atomically when the files change. It is exposed publicly as
+// regobrick.PolicyStore.
+//
+// A PolicyStore is safe for concurrent use: Current and Eval never block on a
+// reload, and reloads are serialized.
+type PolicyStore struct {
+	fsys fs.FS
+	opts PolicyStoreOptions
+
+	current atomic.Pointer[PolicyGeneration]
+
+	// err holds the error of the last reload that found a change. It is
+	// separate from mu so that Err never waits for a reload in progress.
+	err atomic.Pointer[error]
+
+	mu      sync.Mutex // serializes reloads and guards the fields below
+	sources map[string]string
+	parsed  map[string]*ast.Module
+}
+
+// NewPolicyStore loads the files of fsys selected by opts and prepares the
+// queries of opts as the first generation. It returns an error if the files
+// cannot be loaded or a query cannot be prepared.
+func NewPolicyStore(ctx context.Context, fsys fs.FS, opts PolicyStoreOptions) (*PolicyStore, error) {
+	if len(opts.Queries) == 0 {
+		return nil, errors.New("regobrick: policy store has no queries")
+	}
+	s := &PolicyStore{fsys: fsys, opts: opts}
+	if _, err := s.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Current returns the current generation. Evaluating several queries of the
+// returned generation sees a consistent set of policies even if a reload
+// happens meanwhile.
+func (s *PolicyStore) Current() *PolicyGeneration {
+	return s.current.Load()
+}
+
+// Eval evaluates the query prepared under name in the current generation.
+func (s *PolicyStore) Eval(ctx context.Context, name string, opts ...rego.EvalOption) (rego.ResultSet, error) {
+	return s.Current().Eval(ctx, name, opts...)
+}
+
+// Err returns the error of the last reload that found a change, or nil if that
+// reload succeeded. While Err is non-nil, Current still serves the last good
+// generation. Err does not block on a reload in progress; it returns the
+// outcome of the last completed one.
+func (s *PolicyStore) Err() error {
+	if err := s.err.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+// Reload reads the files again and, if any was added, removed or changed,
+// parses the changed files, prepares every query and swaps the new generation
+// in. It reports whether a new generation was swapped in. On error the current
+// generation is kept and the error is returned by Err until a reload finds
+// another change; a reload finding no change returns that error again.
+func (s *PolicyStore) Reload(ctx context.Context) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	gen, err := s.reload(ctx)
+	if gen == nil && err == nil {
+		// No change; a previous error still describes the current files.
+		return false, s.Err()
+	}
+	if err != nil {
+		s.err.Store(&err)
+	} else {
+		s.err.Store(nil)
+	}
+	if err != nil {
+		if s.opts.OnReload != nil && s.current.Load() != nil {
+			s.opts.OnReload(s.current.Load(), err)
+		}
+		return false, err
+	}
+	s.current.Store(gen)
+	if s.opts.OnReload != nil {
+		s.opts.OnReload(gen, nil)
+	}
+	return true, nil
+}
+
+// reload returns the next generation, or nil and no error if no file changed
+// since the last attempt. s.mu must be held.
+func (s *PolicyStore) reload(ctx context.Context) (*PolicyGeneration, error) {
+	files, err := loadFS(s.fsys, FSOptions{Pattern: s.opts.Pattern, Rules: s.opts.Rules, RegoVersion: s.opts.RegoVersion})
+	if err != nil {
+		s.sources, s.parsed = nil, nil
+		return nil, err
+	}
+	sources := make(map[string]string, len(files))
+	for _, f := range files {
+		sources[f.Filename] = f.Source
+	}
+	if s.sources != nil && maps.Equal(sources, s.sources) {
+		return nil, nil
+	}
+
+	// Imports depend only on the path and the source, so a module whose source
+	// did not change is reused as is.
+	parsed := make(map[string]*ast.Module, len(files))
+	var errs []error
+	for _, f := range files {
+		if mod, ok := s.parsed[f.Filename]; ok && s.sources[f.Filename] == f.Source {
+			parsed[f.Filename] = mod
+			continue
+		}
+		mod, err := ParseModuleWithOptions(f.Filename, f.Source, ParseOptions{
+			Imports:            f.Imports,
+			RegoVersion:        s.opts.RegoVersion,
+			Features:           s.opts.Features,
+			ForbidMarkers:      s.opts.ForbidMarkers,
+			BigDecimalLiterals: s.opts.BigDecimalLiterals,
+		})
+		if err != nil {
+			errs = append(errs, &ModuleError{Filename: f.Filename, Err: err})
+			continue
+		}
+		parsed[f.Filename] = mod
+	}
+	s.sources, s.parsed = sources, parsed
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	names := slices.Sorted(maps.Keys(parsed))
+	queries := make(map[string]rego.PreparedEvalQuery, len(s.opts.Queries))
+	for _, name := range slices.Sorted(maps.Keys(s.opts.Queries)) {
+		opts := slices.Clone(s.opts.Options)
+		for _, filename := range names {
+			// The compiler rewrites modules in place; keep the parsed ones
+			// pristine for the next reload.
+			opts = append(opts, rego.ParsedModule(parsed[filename].Copy()))
+		}
+		opts = append(opts, rego.Query(s.opts.Queries[name]))
+		q, err := rego.New(opts...).PrepareForEval(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				// Not caused by the files: retry on the next reload.
+				s.sources, s.parsed = nil, nil
+			}
+			return nil, fmt.Errorf("regobrick: cannot prepare query %q: %w", name, err)
+		}
+		queries[name] = q
+	}
+
+	var id uint64 = 1
+	if cur := s.current.Load(); cur != nil {
+		id = cur.ID + 1
+	}
+	return &PolicyGeneration{ID: id, LoadedAt: time.Now(), Files: names, queries: queries}, nil
+}
+
+// Watch calls Reload every interval until ctx is done, then returns ctx.Err().
+// Reload errors are reported through Err and OnReload.
+func (s *PolicyStore) Watch(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			_, _ = s.Reload(ctx)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package module
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/open-policy-agent/opa/v1/rego"
+)
+
+func storeFS(limit string) fstest.MapFS {
+	return fstest.MapFS{
+		"app/policy.rego": {Data: []byte("package app\nimport data.regobrick.default_false\n\nallow if input.amount < " + limit + "\n")},
+		"app/limit.rego":  {Data: []byte("package app\n\nlimit := " + limit + "\n")},
+	}
+}
+
+func storeOptions() PolicyStoreOptions {
+	return PolicyStoreOptions{
+		Queries: map[string]string{
+			"allow": "data.app.allow",
+			"limit": "data.app.limit",
+		},
+	}
+}
+
+func evalValue(t *testing.T, gen *PolicyGeneration, name string, input any) any {
+	t.Helper()
+	rs, err := gen.Eval(context.Background(), name, rego.EvalInput(input))
+	if err != nil {
+		t.Fatalf("Eval(%q) error: %v", name, err)
+	}
+	if len(rs) != 1 {
+		t.Fatalf("Eval(%q): expected one result, got %v", name, rs)
+	}
+	return rs[0].Expressions[0].Value
+}
+
+func TestPolicyStore_ReloadSwapsGeneration(t *testing.T) {
+	ctx := context.Background()
+	fsys := storeFS("10")
+	store, err := NewPolicyStore(ctx, fsys, storeOptions())
+	if err != nil {
+		t.Fatalf("NewPolicyStore error: %v", err)
+	}
+	first := store.Current()
+	if first.ID != 1 || strings.Join(first.Files, ",") != "app/limit.rego,app/policy.rego" {
+		t.Fatalf("unexpected first generation: %+v", first)
+	}
+	if got := evalValue(t, first, "allow", map[string]any{"amount": 20}); got != false {
+		t.Errorf("expected default false from generation 1, got %v", got)
+	}
+
+	changed, err := store.Reload(ctx)
+	if changed || err != nil {
+		t.Fatalf("expected no change, got changed=%v err=%v", changed, err)
+	}
+
+	fsys["app/policy.rego"] = storeFS("50")["app/policy.rego"]
+	fsys["app/limit.rego"] = storeFS("50")["app/limit.rego"]
+	changed, err = store.Reload(ctx)
+	if !changed || err != nil {
+		t.Fatalf("expected a new generation, got changed=%v err=%v", changed, err)
+	}
+	second := store.Current()
+	if second.ID != 2 {
+		t.Errorf("expected generation 2, got %d", second.ID)
+	}
+	if got := evalValue(t, second, "allow", map[string]any{"amount": 20}); got != true {
+		t.Errorf("expected true from generation 2, got %v", got)
+	}
+
+	// A generation obtained earlier keeps evaluating its own policies.
+	if got := evalValue(t, first, "limit", nil); got != json.Number("10") {
+		t.Errorf("expected limit 10 from generation 1, got %v", got)
+	}
+}
+
+func TestPolicyStore_FailedReloadKeepsGeneration(t *testing.T) {
+	ctx := context.Background()
+	fsys := storeFS("10")
+	var reported []error
+	opts := storeOptions()
+	opts.OnReload = func(gen *PolicyGeneration, err error) {
+		if gen == nil {
+			t.Error("OnReload called without a generation")
+		}
+		reported = append(reported, err)
+	}
+	store, err := NewPolicyStore(ctx, fsys, opts)
+	if err != nil {
+		t.Fatalf("NewPolicyStore error: %v", err)
+	}
+
+	fsys["app/policy.rego"] = &fstest.MapFile{Data: []byte("package app\nimport data.regobrick.no_such_feature\n\nallow if true\n")}
+	changed, err := store.Reload(ctx)
+	if changed || err == nil {
+		t.Fatalf("expected a failed reload, got changed=%v err=%v", changed, err)
+	}
+	var modErr *ModuleError
+	if !errors.As(err, &modErr) || modErr.Filename != "app/policy.rego" {
+		t.Errorf("expected *ModuleError for app/policy.rego, got %v", err)
+	}
+	if store.Err() == nil || store.Current().ID != 1 {
+		t.Errorf("expected generation 1 to be kept with an error, got gen %d err %v", store.Current().ID, store.Err())
+	}
+	if got := evalValue(t, store.Current(), "allow", map[string]any{"amount": 5}); got != true {
+		t.Errorf("expected generation 1 to keep serving, got %v", got)
+	}
+
+	// Unchanged broken files report the same error without another OnReload.
+	if _, err := store.Reload(ctx); err == nil {
+		t.Error("expected the error to persist while the files are unchanged")
+	}
+
+	// A query that no longer compiles fails the reload as well.
+	fsys["app/policy.rego"] = &fstest.MapFile{Data: []byte("package app\n\nallow if undefined_fn(input.amount)\n")}
+	if _, err := store.Reload(ctx); err == nil || !strings.Contains(err.Error(), "cannot prepare query") {
+		t.Errorf("expected a prepare error, got %v", err)
+	}
+
+	fsys["app/policy.rego"] = storeFS("10")["app/policy.rego"]
+	if changed, err := store.Reload(ctx); !changed || err != nil {
+		t.Fatalf("expected recovery, got changed=%v err=%v", changed, err)
+	}
+	if store.Err() != nil || store.Current().ID != 2 {
+		t.Errorf("expected generation 2 without error, got gen %d err %v", store.Current().ID, store.Err())
+	}
+
+	if len(reported) != 4 || reported[0] != nil || reported[1] == nil || reported[2] == nil || reported[3] != nil {
+		t.Errorf("unexpected OnReload errors: %v", reported)
+	}
+}
+
+// TestPolicyStore_ErrDuringReload checks that Err does not wait for a reload in
+// progress, e.g. when OnReload, which runs inside Reload, calls it.
+func TestPolicyStore_ErrDuringReload(t *testing.T) {
+	ctx := context.Background()
+	fsys := storeFS("10")
+	var store *PolicyStore
+	var seen []error
+	opts := storeOptions()
+	opts.OnReload = func(_ *PolicyGeneration, err error) {
+		if store == nil {
+			return
+		}
+		done := make(chan error, 1)
+		go func() { done <- store.Err() }()
+		select {
+		case got := <-done:
+			seen = append(seen, got)
+			if (got == nil) != (err == nil) {
+				t.Errorf("Err() = %v during OnReload(%v)", got, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Err blocked during a reload")
+		}
+	}
+	store, err := NewPolicyStore(ctx, fsys, opts)
+	if err != nil {
+		t.Fatalf("NewPolicyStore error: %v", err)
+	}
+
+	fsys["app/policy.rego"] = &fstest.MapFile{Data: []byte("package app\nallow if {\n")}
+	if _, err := store.Reload(ctx); err == nil {
+		t.Fatal("expected a failed reload")
+	}
+	fsys["app/policy.rego"] = storeFS("20")["app/policy.rego"]
+	if _, err := store.Reload(ctx); err != nil {
+		t.Fatalf("Reload error: %v", err)
+	}
+	if len(seen) != 2 {
+		t.Errorf("expected OnReload to run twice, got %v", seen)
+	}
+}
+
+// TestPolicyStore_InitialLoadFailureSkipsOnReload checks that a failing first
+// load is only reported by NewPolicyStore, never to OnReload.
+func TestPolicyStore_InitialLoadFailureSkipsOnReload(t *testing.T) {
+	fsys := storeFS("10")
+	fsys["app/broken.rego"] = &fstest.MapFile{Data: []byte("package app\nallow if {\n")}
+	called := 0
+	opts := storeOptions()
+	opts.OnReload = func(*PolicyGeneration, error) { called++ }
+	if _, err := NewPolicyStore(context.Background(), fsys, opts); err == nil {
+		t.Fatal("expected NewPolicyStore to fail")
+	}
+	if called != 0 {
+		t.Errorf("expected OnReload not to be called, got %d calls", called)
+	}
+
+	delete(fsys, "app/broken.rego")
+	if _, err := NewPolicyStore(context.Background(), fsys, opts); err != nil {
+		t.Fatalf("NewPolicyStore error: %v", err)
+	}
+	if called != 1 {
+		t.Errorf("expected OnReload for the first generation, got %d calls", called)
+	}
+}
+
+// TestPolicyStore_RegoV0PackageRules checks that package rules match v0 modules
+// loaded with RegoVersion set to ast.RegoV0.
+func TestPolicyStore_RegoV0PackageRules(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app/policy.rego": {Data: []byte("package app\n\nallow { input.amount < limits.max }\n")},
+		"lib/limits.rego": {Data: []byte("package lib.limits\n\nmax = 10\n")},
+	}
+	store, err := NewPolicyStore(context.Background(), fsys, PolicyStoreOptions{
+		Rules:       []ImportRule{ImportsForPackage("app", "data.lib.limits")},
+		RegoVersion: ast.RegoV0,
+		Queries:     map[string]string{"allow": "data.app.allow"},
+	})
+	if err != nil {
+		t.Fatalf("NewPolicyStore error: %v", err)
+	}
+	if got := evalValue(t, store.Current(), "allow", map[string]any{"amount": 5}); got != true {
+		t.Errorf("expected allow, got %v", got)
+	}
+}
+
+func TestPolicyStore_Errors(t *testing.T) {
+	ctx := context.Background()
+	if _, err := NewPolicyStore(ctx, storeFS("10"), PolicyStoreOptions{}); err == nil {
+		t.Error("expected an error without queries")
+	}
+
+	fsys := storeFS("10")
+	fsys["app/broken.rego"] = &fstest.MapFile{Data: []byte("package app\nimport data.regobrick.default_false\nallow if {\n")}
+	if _, err := NewPolicyStore(ctx, fsys, storeOptions()); err == nil || !strings.Contains(err.Error(), `"app/broken.rego"`) {
+		t.Errorf("expected the first load to fail on app/broken.rego, got %v", err)
+	}
+
+	store, err := NewPolicyStore(ctx, storeFS("10"), storeOptions())
+	if err != nil {
+		t.Fatalf("NewPolicyStore error: %v", err)
+	}
+	if _, err := store.Eval(ctx, "missing"); err == nil || !strings.Contains(err.Error(), "unknown query") {
+		t.Errorf("expected unknown query error, got %v", err)
+	}
+}
+
+func TestPolicyStore_ConcurrentEvalDuringReload(t *testing.T) {
+	ctx := context.Background()
+	fsys := storeFS("10")
+	store, err := NewPolicyStore(ctx, fsys, storeOptions())
+	if err != nil {
+		t.Fatalf("NewPolicyStore error: %v", err)
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				// Both queries of one generation agree on the limit.
+				gen := store.Current()
+				limit, err := gen.Eval(ctx, "limit")
+				if err != nil || len(limit) != 1 {
+					t.Errorf("generation %d: limit = %v, %v", gen.ID, limit, err)
+					return
+				}
+				allow, err := gen.Eval(ctx, "allow", rego.EvalInput(map[string]any{"amount": 20}))
+				if err != nil || len(allow) != 1 {
+					t.Errorf("generation %d: allow = %v, %v", gen.ID, allow, err)
+					return
+				}
+				want := limit[0].Expressions[0].Value == json.Number("50")
+				if got := allow[0].Expressions[0].Value; got != want {
+					t.Errorf("generation %d: limit %v but allow(20) = %v", gen.ID, limit[0].Expressions[0].Value, got)
+				}
+			}
+		}()
+	}
+
+	for i := range 10 {
+		limit := "10"
+		if i%2 == 0 {
+			limit = "50"
+		}
+		next := storeFS(limit)
+		// The store reads the files under its reload lock; swap them between
+		// reloads only.
+		fsys["app/policy.rego"], fsys["app/limit.rego"] = next["app/policy.rego"], next["app/limit.rego"]
+		if _, err := store.Reload(ctx); err != nil {
+			t.Errorf("Reload error: %v", err)
+			break
+		}
+	}
+	close(done)
+	wg.Wait()
+	if store.Current().ID != 11 {
+		t.Errorf("expected generation 11, got %d", store.Current().ID)
+	}
+}
+
+func TestPolicyStore_WatchDirectory(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, src string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		// Write and rename so the poller never reads a partial file.
+		if err := os.WriteFile(path+".tmp", []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(path+".tmp", path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, f := range storeFS("10") {
+		write(name, string(f.Data))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store, err := NewPolicyStore(ctx, os.DirFS(dir), storeOptions())
+	if err != nil {
+		t.Fatalf("NewPolicyStore error: %v", err)
+	}
+	watchErr := make(chan error, 1)
+	go func() { watchErr <- store.Watch(ctx, 10*time.Millisecond) }()
+
+	write("app/limit.rego", string(storeFS("50")["app/limit.rego"].Data))
+	deadline := time.Now().Add(5 * time.Second)
+	for store.Current().ID == 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("Watch did not pick up the change")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	cancel()
+	if err := <-watchErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected Watch to return context.Canceled, got %v", err)
+	}
+}
@@ -0,0 +1,249 @@
+package regobrick
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/open-policy-agent/opa/v1/types"
+)
+
+// Diagnostic is a finding of a static policy check such as LintDecimalSafety.
+// Diagnostics have JSON tags so they can be emitted by CI tooling as is.
+type Diagnostic struct {
+	// Code identifies the check that produced the diagnostic, e.g.
+	// "decimal-unsafe-builtin".
+	Code string `json:"code"`
+	// Message describes the finding.
+	Message string `json:"message"`
+	// Location is the position of the offending expression in the policy source.
+	Location *ast.Location `json:"location,omitempty"`
+	// Builtin is the name of the builtin involved, if any.
+	Builtin string `json:"builtin,omitempty"`
+	// Suggestion describes a decimal-safe replacement, if there is one.
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// String formats d as "<file>:<row>: <message> (<suggestion>)".
+func (d Diagnostic) String() string {
+	s := d.Message
+	if d.Location != nil {
+		s = d.Location.String() + ": " + s
+	}
+	if d.Suggestion != "" {
+		s += " (" + d.Suggestion + ")"
+	}
+	return s
+}
+
+// DiagnosticDecimalUnsafeBuiltin is the Diagnostic.Code reported by
+// LintDecimalSafety.
+const DiagnosticDecimalUnsafeBuiltin = "decimal-unsafe-builtin"
+
+// decimalUnsafeBuiltins maps the numeric builtins that UseDecimalArithmetic does
+// not overload (see registerDecimalBuiltins) to a decimal-safe replacement. They
+// convert numbers through float64 or big.Float and can reintroduce the
+// imprecision decimal arithmetic removes.
+var decimalUnsafeBuiltins = map[string]string{
+	ast.NumbersRangeStep.Name: "use numbers.range over integer bounds and multiply each element by the step",
+	ast.UnitsParse.Name:       "pass quantities in base units, or parse them with a custom builtin returning a Number",
+	ast.UnitsParseBytes.Name:  "pass byte counts as integers, or parse them with a custom builtin returning a Number",
+	ast.ToNumber.Name:         "use UseDecimalArithmetic(WithStringCoercion()) so numeric strings are parsed as decimals by the operators",
+	ast.FormatInt.Name:        "round the value with round/floor/ceil first, or format it with a custom builtin",
+	ast.Sprintf.Name:          "render numbers with json.marshal(x), which keeps the exact decimal text",
+}
+
+// LintDecimalSafety reports every call in modules to a numeric builtin that
+// UseDecimalArithmetic does not overload, such as units.parse, to_number,
+// format_int, numbers.range_step and sprintf. These builtins go through float
+// paths, so a policy that relies on decimal arithmetic should avoid them on
+// monetary values; each Diagnostic carries the call's location and a suggested
+// replacement.
+//
+// Diagnostics are sorted by file, row and column. The check is purely
+// syntactic: it does not know which arguments hold monetary values, so sprintf
+// with only string arguments is reported as well.
+//
+// Example:
+//
+//	mod, _ := regobrick.ParseModule("pricing.rego", src, nil)
+//	for _, d := range regobrick.LintDecimalSafety([]*ast.Module{mod}) {
+//	    fmt.Println(d)
+//	}
+func LintDecimalSafety(modules []*ast.Module) []Diagnostic {
+	var diags []Diagnostic
+	report := func(name string, loc *ast.Location) {
+		suggestion, ok := decimalUnsafeBuiltins[name]
+		if !ok {
+			return
+		}
+		diags = append(diags, Diagnostic{
+			Code:       DiagnosticDecimalUnsafeBuiltin,
+			Message:    fmt.Sprintf("%s is not overloaded by UseDecimalArithmetic and uses floating-point conversion", name),
+			Location:   loc,
+			Builtin:    name,
+			Suggestion: suggestion,
+		})
+	}
+
+	for _, mod := range modules {
+		// Calls in statement position, e.g. "units.parse(x, y)".
+		ast.WalkExprs(mod, func(expr *ast.Expr) bool {
+			if expr.IsCall() {
+				report(expr.Operator().String(), expr.Location)
+			}
+			return false
+		})
+		// Calls nested in terms, e.g. "y := units.parse(x)".
+		ast.WalkTerms(mod, func(t *ast.Term) bool {
+			if call, ok := t.Value.(ast.Call); ok && len(call) > 0 {
+				report(call[0].String(), t.Location)
+			}
+			return false
+		})
+	}
+
+	sortDiagnostics(diags)
+	return diags
+}
+
+// DiagnosticDecimalNonNumericComparison is the Diagnostic.Code reported by
+// LintDecimalComparisons.
+const DiagnosticDecimalNonNumericComparison = "decimal-non-numeric-comparison"
+
+// decimalComparisonOperators maps the ordering operators that UseDecimalArithmetic
+// makes numeric-only to their infix form. == and != keep OPA's equality for
+// non-numbers.
+var decimalComparisonOperators = map[string]string{
+	ast.GreaterThan.Name:   ast.GreaterThan.Infix,
+	ast.GreaterThanEq.Name: ast.GreaterThanEq.Infix,
+	ast.LessThan.Name:      ast.LessThan.Infix,
+	ast.LessThanEq.Name:    ast.LessThanEq.Infix,
+}
+
+// LintDecimalComparisons reports every <, <=, > and >= in modules with an operand
+// that is statically non-numeric. Standard OPA orders such operands by type
+// ("a" < "b" is true), but under UseDecimalArithmetic the comparison is undefined,
+// so these are the expressions to review before enabling decimal mode on an
+// existing policy set.
+//
+// An operand is statically non-numeric when it is a non-number literal (a string,
+// boolean, null or collection) or an input reference whose type under the input
+// schema of schemas (the set passed to rego.Schemas) is not numeric, e.g. a string
+// property. schemas may be nil, in which case only literals are checked. Operands
+// whose type depends on evaluation (variables, rule references, untyped input) are
+// not reported.
+//
+// A numeric string ("0.5" or an input string holding one) is still reported: it
+// only compares as a number with WithStringCoercion, as the message notes.
+//
+// LintDecimalComparisons returns an error if the input schema cannot be loaded.
+// Diagnostics are sorted by file, row and column.
+func LintDecimalComparisons(modules []*ast.Module, schemas *ast.SchemaSet) ([]Diagnostic, error) {
+	env, err := inputTypeEnv(schemas)
+	if err != nil {
+		return nil, err
+	}
+
+	var diags []Diagnostic
+	check := func(op string, operands []*ast.Term, loc *ast.Location) {
+		infix, ok := decimalComparisonOperators[op]
+		if !ok || len(operands) < 2 {
+			return
+		}
+		for _, operand := range operands[:2] {
+			reason := nonNumericOperand(operand, env)
+			if reason == "" {
+				continue
+			}
+			diags = append(diags, Diagnostic{
+				Code: DiagnosticDecimalNonNumericComparison,
+				Message: fmt.Sprintf(
+					"%s compares %s, which is %s: undefined under UseDecimalArithmetic instead of OPA type ordering",
+					infix, operand, reason,
+				),
+				Location:   loc,
+				Builtin:    op,
+				Suggestion: "compare numbers only, or keep this policy on standard arithmetic; numeric strings compare as numbers only with WithStringCoercion",
+			})
+			break
+		}
+	}
+
+	for _, mod := range modules {
+		ast.WalkExprs(mod, func(expr *ast.Expr) bool {
+			if expr.IsCall() {
+				check(expr.Operator().String(), expr.Operands(), expr.Location)
+			}
+			return false
+		})
+		ast.WalkTerms(mod, func(t *ast.Term) bool {
+			if call, ok := t.Value.(ast.Call); ok && len(call) > 0 {
+				check(call[0].String(), call[1:], t.Location)
+			}
+			return false
+		})
+	}
+
+	sortDiagnostics(diags)
+	return diags, nil
+}
+
+// nonNumericOperand describes why t is statically non-numeric, e.g. "a string
+// literal", or returns "" if it may be a number.
+func nonNumericOperand(t *ast.Term, env *ast.TypeEnv) string {
+	switch v := t.Value.(type) {
+	case ast.Number:
+		return ""
+	case ast.String:
+		return "a string literal"
+	case ast.Boolean:
+		return "a boolean literal"
+	case ast.Null:
+		return "a null literal"
+	case *ast.Array, ast.Object, ast.Set,
+		*ast.ArrayComprehension, *ast.ObjectComprehension, *ast.SetComprehension:
+		return "a collection"
+	case ast.Ref:
+		if env == nil || !v.HasPrefix(ast.InputRootRef) {
+			return ""
+		}
+		tpe := env.Get(v)
+		if tpe == nil || types.Contains(tpe, types.N) {
+			return ""
+		}
+		return "typed " + types.Sprint(tpe) + " by the input schema"
+	}
+	return ""
+}
+
+// inputTypeEnv returns the type environment holding the input schema of
+// schemas, or nil if there is none.
+func inputTypeEnv(schemas *ast.SchemaSet) (*ast.TypeEnv, error) {
+	if schemas == nil || schemas.Get(ast.SchemaRootRef) == nil {
+		return nil, nil
+	}
+	c := ast.NewCompiler().WithSchemas(schemas)
+	c.Compile(map[string]*ast.Module{})
+	if c.Failed() {
+		return nil, fmt.Errorf("regobrick: cannot load input schema: %v", c.Errors)
+	}
+	return c.TypeEnv, nil
+}
+
+// sortDiagnostics orders diags by file, row and column.
+func sortDiagnostics(diags []Diagnostic) {
+	sort.SliceStable(diags, func(i, j int) bool {
+		a, b := diags[i].Location, diags[j].Location
+		if a == nil || b == nil {
+			return a != nil
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Row != b.Row {
+			return a.Row < b.Row
+		}
+		return a.Col < b.Col
+	})
+}
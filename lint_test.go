@@ -0,0 +1,154 @@
+package regobrick_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/sky1core/regobrick"
+)
+
+func TestLintDecimalSafety(t *testing.T) {
+	policy := `package pricing
+
+fee := input.amount * 0.0025
+
+limit := units.parse(input.limit)
+
+label := sprintf("fee: %v", [fee])
+
+steps := numbers.range_step(0, 1, 0.1)
+
+hex := format_int(input.n, 16)
+
+parsed := [to_number(s) | some s in input.values]
+
+allow if {
+	units.parse_bytes(input.size, n)
+	n < 100
+}
+
+total := sum([1, 2]) + round(1.5)
+`
+	mod, err := regobrick.ParseModule("pricing.rego", policy, nil)
+	if err != nil {
+		t.Fatalf("ParseModule failed: %v", err)
+	}
+
+	diags := regobrick.LintDecimalSafety([]*ast.Module{mod})
+
+	var got []string
+	for _, d := range diags {
+		if d.Code != regobrick.DiagnosticDecimalUnsafeBuiltin {
+			t.Errorf("unexpected code %q", d.Code)
+		}
+		if d.Suggestion == "" {
+			t.Errorf("%s: expected a suggestion", d.Builtin)
+		}
+		got = append(got, d.Builtin+"@"+d.Location.String())
+	}
+	want := []string{
+		"units.parse@pricing.rego:5",
+		"sprintf@pricing.rego:7",
+		"numbers.range_step@pricing.rego:9",
+		"format_int@pricing.rego:11",
+		"to_number@pricing.rego:13",
+		"units.parse_bytes@pricing.rego:16",
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	if s := diags[0].String(); !strings.HasPrefix(s, "pricing.rego:5: units.parse ") {
+		t.Errorf("unexpected String() output: %s", s)
+	}
+	if _, err := json.Marshal(diags); err != nil {
+		t.Errorf("diagnostics should marshal to JSON: %v", err)
+	}
+}
+
+func TestLintDecimalSafety_Clean(t *testing.T) {
+	mod, err := regobrick.ParseModule("clean.rego", "package clean\n\nfee := input.amount * 0.0025\n", nil)
+	if err != nil {
+		t.Fatalf("ParseModule failed: %v", err)
+	}
+	if diags := regobrick.LintDecimalSafety([]*ast.Module{mod}); len(diags) != 0 {
+		t.Errorf("expected no diagnostics, got %v", diags)
+	}
+}
+
+func TestLintDecimalComparisons(t *testing.T) {
+	policy := `package orders
+
+by_name if input.user.name < "m"
+
+late if input.created_at > input.deadline
+
+big if input.amount > 100
+
+flag if input.enabled >= true
+
+ok if 1 < 2
+
+numeric_string if input.qty > "0.5"
+
+compare_vars if {
+	some x in input.items
+	x < 3
+}
+`
+	mod, err := regobrick.ParseModule("orders.rego", policy, nil)
+	if err != nil {
+		t.Fatalf("ParseModule failed: %v", err)
+	}
+
+	schemas := ast.NewSchemaSet()
+	schemas.Put(ast.SchemaRootRef, map[string]any{
+		"type": "object",
+		"properties": map[string]any{
+			"user": map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"name": map[string]any{"type": "string"}},
+			},
+			"created_at": map[string]any{"type": "string"},
+			"deadline":   map[string]any{"type": "string"},
+			"amount":     map[string]any{"type": "number"},
+			"enabled":    map[string]any{"type": "boolean"},
+			"qty":        map[string]any{"type": "number"},
+		},
+	})
+
+	diags, err := regobrick.LintDecimalComparisons([]*ast.Module{mod}, schemas)
+	if err != nil {
+		t.Fatalf("LintDecimalComparisons failed: %v", err)
+	}
+	var rows []string
+	for _, d := range diags {
+		if d.Code != regobrick.DiagnosticDecimalNonNumericComparison {
+			t.Errorf("unexpected code %q", d.Code)
+		}
+		rows = append(rows, d.Location.String())
+	}
+	want := []string{"orders.rego:3", "orders.rego:5", "orders.rego:9", "orders.rego:13"}
+	if strings.Join(rows, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected diagnostics at %v, got %v", want, diags)
+	}
+	if !strings.Contains(diags[1].Message, "input.created_at") || !strings.Contains(diags[1].Message, "string") {
+		t.Errorf("message should name the operand and its schema type, got: %s", diags[1].Message)
+	}
+
+	// Without schemas only literals are reported.
+	diags, err = regobrick.LintDecimalComparisons([]*ast.Module{mod}, nil)
+	if err != nil {
+		t.Fatalf("LintDecimalComparisons failed: %v", err)
+	}
+	rows = rows[:0]
+	for _, d := range diags {
+		rows = append(rows, d.Location.String())
+	}
+	want = []string{"orders.rego:3", "orders.rego:9", "orders.rego:13"}
+	if strings.Join(rows, ",") != strings.Join(want, ",") {
+		t.Errorf("expected literal diagnostics at %v, got %v", want, diags)
+	}
+}
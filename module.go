@@ -3,6 +3,8 @@
 package regobrick
 
 import (
+	"io/fs"
+
 	"github.com/open-policy-agent/opa/v1/ast"
 	"github.com/open-policy-agent/opa/v1/rego"
 	"github.com/sky1core/regobrick/internal/module"
@@ -13,19 +15,109 @@ import (
 type ModuleOption = module.ModuleOption
 
 // ParseModule parses a Rego source file into an AST module, optionally appending
-// additional imports. If the module includes "import data.regobrick.default_false",
-// it applies the default_false transform. METADATA annotations are preserved.
+// additional imports ("data.lib.money", or "data.lib.v2.money as money2" with an
+// alias). If the module includes "import data.regobrick.default_false",
+// "import data.regobrick.default_false_functions",
+// "import data.regobrick.default_empty", "import data.regobrick.default_value" or
+// "import data.regobrick.collect_reasons", it applies the matching transform.
+// METADATA annotations are preserved.
 //
 // Any "data.regobrick.*" marker import is stripped from the returned module: it
 // only triggers transforms and would otherwise be an unused import. This is
 // observable to callers using rego.Strict(true), which rejects unused imports.
 //
 // ParseModule never panics; it returns an error for parse failures, invalid
-// injected import paths, import name conflicts, or unknown regobrick features.
+// injected import paths or aliases, import name conflicts, unknown regobrick
+// features, or default_value annotations that cannot be applied.
 func ParseModule(filename, src string, imports []string) (*ast.Module, error) {
 	return module.ParseModule(filename, src, imports)
 }
 
+// ParseOptions is an alias for module.ParseOptions. It carries the imports, the
+// Rego version (e.g. ast.RegoV0), the features enabled by the host, and whether
+// source-level "data.regobrick.*" markers are forbidden.
+type ParseOptions = module.ParseOptions
+
+// ParseModuleWithOptions behaves like ParseModule, with the imports and the Rego
+// version of src taken from opts. Set opts.RegoVersion to ast.RegoV0 to inject
+// imports and apply features to v0 modules; synthesized defaults then use v0
+// syntax ("default allow = false").
+//
+// opts.Features applies features as if the source imported
+// "data.regobrick.<feature>" for each of them. With opts.ForbidMarkers, a source
+// containing such a marker import is rejected, so a platform team can enforce the
+// features of every tenant policy centrally:
+//
+//	mod, err := regobrick.ParseModuleWithOptions("tenant.rego", src, regobrick.ParseOptions{
+//	    Features:      []string{"default_false"},
+//	    ForbidMarkers: true,
+//	})
+func ParseModuleWithOptions(filename, src string, opts ParseOptions) (*ast.Module, error) {
+	return module.ParseModuleWithOptions(filename, src, opts)
+}
+
+// Report is an alias for module.Report. It describes what ParseModuleWithReport
+// changed in a module: applied features, synthesized default rules, every rule
+// a feature added, rewrote or removed, injected and deduplicated imports, and
+// removed "data.regobrick.*" marker imports.
+type Report = module.Report
+
+// DefaultRule is an alias for module.DefaultRule, describing one default rule
+// synthesized by a feature.
+type DefaultRule = module.DefaultRule
+
+// RuleChange is an alias for module.RuleChange, describing one rule a feature
+// added, rewrote or removed.
+type RuleChange = module.RuleChange
+
+// Kinds of RuleChange.
+const (
+	RuleAdded     = module.RuleAdded
+	RuleRewritten = module.RuleRewritten
+	RuleRemoved   = module.RuleRemoved
+)
+
+// ParseModuleWithReport behaves like ParseModule and additionally returns a
+// Report of every change made to the module, for logging at policy load or
+// asserting on in policy-review tests. The report is nil whenever an error is
+// returned.
+func ParseModuleWithReport(filename, src string, imports []string) (*ast.Module, *Report, error) {
+	return module.ParseModuleWithReport(filename, src, imports)
+}
+
+// ParseModuleWithOptionsReport behaves like ParseModuleWithOptions and
+// additionally returns the Report of ParseModuleWithReport, e.g. for v0 modules
+// or features enabled by the host through opts.Features.
+func ParseModuleWithOptionsReport(filename, src string, opts ParseOptions) (*ast.Module, *Report, error) {
+	return module.ParseModuleWithOptionsReport(filename, src, opts)
+}
+
+// GeneratedBy reports whether rule is a default rule synthesized by a regobrick
+// feature (e.g. "default_false"), and which feature synthesized it. Synthesized
+// rules carry the location of the rule they default, so compiler errors,
+// coverage reports and traces name the original file and line; GeneratedBy tells
+// them apart from rules written in the source of a module that enables a
+// feature. A module that enables none is not validated, so a hand-written
+// "custom.regobrick.generated" annotation in it is reported as is.
+func GeneratedBy(rule *ast.Rule) (feature string, ok bool) {
+	return module.GeneratedBy(rule)
+}
+
+// FormatModule runs ParseModule on src and imports and renders the transformed
+// module as formatted Rego source through OPA's formatter. The output shows the
+// effective policy: synthesized default rules and injected imports are included
+// and the "data.regobrick.*" marker imports are dropped. The same is available
+// from the command line with "go run github.com/sky1core/regobrick/cmd/regobrick-expand".
+func FormatModule(filename, src string, imports []string) ([]byte, error) {
+	return module.FormatModule(filename, src, imports)
+}
+
+// FormatModuleWithOptions behaves like FormatModule, with the imports and the
+// Rego version taken from opts. The output uses the syntax of that version.
+func FormatModuleWithOptions(filename, src string, opts ParseOptions) ([]byte, error) {
+	return module.FormatModuleWithOptions(filename, src, opts)
+}
+
 // Module returns a rego.Rego option that adds a single Rego module from the given
 // filename, source, and optional imports.
 //
@@ -34,16 +126,96 @@ func ParseModule(filename, src string, imports []string) (*ast.Module, error) {
 // caller requested nothing regobrick-specific (imports is empty AND the source does
 // not reference any "data.regobrick." feature). In that case Module falls back to
 // rego.Module(filename, src), preserving plain (including v0) workflows. If you want
-// to handle errors yourself instead of risking a panic, call ParseModule and pass
-// the resulting *ast.Module to rego.ParsedModule(...).
+// to handle errors yourself instead of risking a panic, use a ModuleSet, or call
+// ParseModule and pass the resulting *ast.Module to rego.ParsedModule(...).
 func Module(filename, src string, imports []string) func(*rego.Rego) {
 	return module.Module(filename, src, imports)
 }
 
 // Modules returns a rego.Rego option that adds multiple Rego modules in one call,
-// each specified via a ModuleOption. It follows the same fail-fast contract as
-// Module: a module that requested regobrick behavior but failed to parse causes a
-// panic, while a fully plain module falls back to rego.Module.
+// each specified via a ModuleOption. A ModuleOption with a RegoVersion (e.g.
+// ast.RegoV0) is parsed with that version. It follows the same fail-fast contract
+// as Module: a module that requested regobrick behavior but failed to parse causes
+// a panic, while a fully plain module falls back to rego.Module.
 func Modules(opts ...ModuleOption) func(*rego.Rego) {
 	return module.Modules(opts...)
 }
+
+// ModuleSet is an alias for module.ModuleSet. It is the error-returning
+// counterpart of Modules: modules are added with Add and turned into rego.Rego
+// options by Options, which reports every failing module instead of panicking.
+type ModuleSet = module.ModuleSet
+
+// ModuleError is an alias for module.ModuleError. It identifies the module (by
+// filename) that a ModuleSet could not process.
+type ModuleError = module.ModuleError
+
+// NewModuleSet returns an empty ModuleSet.
+//
+// Example:
+//
+//	opts, err := regobrick.NewModuleSet().
+//	    Add("policy.rego", policySrc, []string{"data.lib.money"}).
+//	    Add("lib/money.rego", moneySrc, nil).
+//	    Options()
+//	if err != nil {
+//	    return err // one *ModuleError per failing module, joined with errors.Join
+//	}
+//	r := rego.New(append(opts, rego.Query("data.policy.allow"))...)
+func NewModuleSet() *ModuleSet {
+	return module.NewModuleSet()
+}
+
+// ImportRule is an alias for module.ImportRule. It selects, by package or file
+// path glob, the imports ModulesFS injects into each loaded module.
+type ImportRule = module.ImportRule
+
+// ImportsForPackage returns an ImportRule injecting imports into every module
+// whose package (without the "data." prefix) matches pattern, e.g. "app.billing.*"
+// for the direct children of app.billing or "app.billing.**" for the whole
+// subtree.
+func ImportsForPackage(pattern string, imports ...string) ImportRule {
+	return module.ImportsForPackage(pattern, imports...)
+}
+
+// ImportsForPath returns an ImportRule injecting imports into every module whose
+// slash-separated path within the fs.FS matches pattern, e.g. "billing/**".
+func ImportsForPath(pattern string, imports ...string) ImportRule {
+	return module.ImportsForPath(pattern, imports...)
+}
+
+// ModulesFS returns a rego.Rego option that adds every ".rego" file of fsys (an
+// embed.FS, os.DirFS, ...) whose path matches pattern, with the imports selected
+// by rules. An empty pattern loads every ".rego" file; "**" matches any number of
+// directories. Files are named by their path within fsys.
+//
+// Each module follows the fail-fast contract of Module. ModulesFS also panics if
+// fsys cannot be walked, a pattern is malformed, or no file matches. Use
+// ModuleSet.AddFS to get these errors returned instead.
+//
+// Example:
+//
+//	//go:embed policies
+//	var policies embed.FS
+//
+//	rego.New(
+//	    regobrick.ModulesFS(policies, "policies/**",
+//	        regobrick.ImportsForPackage("app.billing.**", "data.lib.money")),
+//	    rego.Query("data.app.billing.allow"),
+//	)
+func ModulesFS(fsys fs.FS, pattern string, rules ...ImportRule) func(*rego.Rego) {
+	return module.ModulesFS(fsys, pattern, rules...)
+}
+
+// FSOptions is an alias for module.FSOptions. It configures
+// ModulesFSWithOptions and ModuleSet.AddFSWithOptions: the file pattern, the
+// import rules, and the Rego version and features of the loaded modules.
+type FSOptions = module.FSOptions
+
+// ModulesFSWithOptions behaves like ModulesFS, with the Rego version and the
+// features of the modules taken from opts. Set opts.RegoVersion to ast.RegoV0 to
+// load v0 modules; their package is then read with the v0 syntax as well, so
+// ImportsForPackage rules match them.
+func ModulesFSWithOptions(fsys fs.FS, opts FSOptions) func(*rego.Rego) {
+	return module.ModulesFSWithOptions(fsys, opts)
+}
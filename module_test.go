@@ -2,8 +2,11 @@ package regobrick_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"strings"
 	"testing"
+	"testing/fstest"
 
 	"github.com/open-policy-agent/opa/v1/ast"
 	"github.com/open-policy-agent/opa/v1/rego"
@@ -369,6 +372,128 @@ func TestModule_SyntaxError(t *testing.T) {
 	}
 }
 
+func TestModule_DefaultEmpty(t *testing.T) {
+	ctx := context.Background()
+
+	policy := `
+		package test
+		import data.regobrick.default_empty
+
+		admins := {u | some u in input.users; u != "guest"} if input.enabled
+		limits := {"eu": 10} if input.enabled
+		deny contains msg if {
+			input.blocked
+			msg := "blocked"
+		}
+	`
+
+	query, err := rego.New(
+		rego.Strict(true),
+		regobrick.Module("test.rego", policy, nil),
+		rego.Query("data.test"),
+	).PrepareForEval(ctx)
+	if err != nil {
+		t.Fatalf("PrepareForEval failed: %v", err)
+	}
+
+	rs, err := query.Eval(ctx, rego.EvalInput(map[string]any{}))
+	if err != nil {
+		t.Fatalf("Eval failed: %v", err)
+	}
+	if len(rs) == 0 || len(rs[0].Expressions) == 0 {
+		t.Fatal("expected result, got empty")
+	}
+	obj, ok := rs[0].Expressions[0].Value.(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected object result, got %T", rs[0].Expressions[0].Value)
+	}
+	for _, name := range []string{"admins", "deny"} {
+		if arr, ok := obj[name].([]interface{}); !ok || len(arr) != 0 {
+			t.Errorf("expected %s to be an empty set, got %v", name, obj[name])
+		}
+	}
+	if m, ok := obj["limits"].(map[string]interface{}); !ok || len(m) != 0 {
+		t.Errorf("expected limits to be an empty object, got %v", obj["limits"])
+	}
+}
+
+func TestModule_DefaultValue(t *testing.T) {
+	ctx := context.Background()
+
+	policy := `
+package test
+import data.regobrick.default_value
+
+# METADATA
+# custom:
+#   default: 0
+discount := input.amount * 0.1 if input.member
+
+# METADATA
+# custom:
+#   default: "none"
+tier := "gold" if input.vip
+`
+
+	query, err := rego.New(
+		regobrick.Module("test.rego", policy, nil),
+		rego.Query("data.test"),
+	).PrepareForEval(ctx)
+	if err != nil {
+		t.Fatalf("PrepareForEval failed: %v", err)
+	}
+
+	rs, err := query.Eval(ctx, rego.EvalInput(map[string]any{"amount": 100}))
+	if err != nil {
+		t.Fatalf("Eval failed: %v", err)
+	}
+	if len(rs) == 0 || len(rs[0].Expressions) == 0 {
+		t.Fatal("expected result, got empty")
+	}
+	obj, ok := rs[0].Expressions[0].Value.(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected object result, got %T", rs[0].Expressions[0].Value)
+	}
+	if n, ok := obj["discount"].(json.Number); !ok || n.String() != "0" {
+		t.Errorf("expected discount 0, got %v", obj["discount"])
+	}
+	if obj["tier"] != "none" {
+		t.Errorf("expected tier none, got %v", obj["tier"])
+	}
+}
+
+func TestModule_DefaultFalse_OptOut(t *testing.T) {
+	ctx := context.Background()
+
+	policy := `
+package test
+import data.regobrick.default_false
+
+# METADATA
+# custom:
+#   regobrick:
+#     default_false: false
+applicable if { input.kind == "order" }
+`
+
+	query, err := rego.New(
+		regobrick.Module("test.rego", policy, nil),
+		rego.Query("data.test.applicable"),
+	).PrepareForEval(ctx)
+	if err != nil {
+		t.Fatalf("PrepareForEval failed: %v", err)
+	}
+
+	// applicable = undefined when the body fails (opted out of default_false)
+	rs, err := query.Eval(ctx, rego.EvalInput(map[string]any{"kind": "refund"}))
+	if err != nil {
+		t.Fatalf("Eval failed: %v", err)
+	}
+	if len(rs) != 0 {
+		t.Errorf("expected undefined (len(rs)==0), got %d results: %v", len(rs), rs)
+	}
+}
+
 // TestModule_DefaultFalse_StrictMode verifies that a default_false-transformed module
 // compiles under rego.Strict(true) (fix B: the marker import is removed, so there should be no unused import error).
 func TestModule_DefaultFalse_StrictMode(t *testing.T) {
@@ -506,3 +631,360 @@ func TestParseModule_Direct(t *testing.T) {
 		t.Error("expected default allow rule to be added")
 	}
 }
+
+func TestParseModuleWithReport_Direct(t *testing.T) {
+	policy := `
+		package test
+		import data.regobrick.default_false
+
+		allow if {
+			helper.ok
+		}
+	`
+
+	module, report, err := regobrick.ParseModuleWithReport("test.rego", policy, []string{"data.helper"})
+	if err != nil {
+		t.Fatalf("ParseModuleWithReport failed: %v", err)
+	}
+	if module == nil || report == nil {
+		t.Fatal("expected module and report, got nil")
+	}
+
+	if len(report.Features) != 1 || report.Features[0] != "default_false" {
+		t.Errorf("expected features [default_false], got %v", report.Features)
+	}
+	want := regobrick.DefaultRule{Feature: "default_false", Ref: "allow", Value: "false"}
+	if len(report.DefaultRules) != 1 || report.DefaultRules[0] != want {
+		t.Errorf("expected default rules [%v], got %v", want, report.DefaultRules)
+	}
+	if len(report.InjectedImports) != 1 || report.InjectedImports[0] != "data.helper" {
+		t.Errorf("expected injected imports [data.helper], got %v", report.InjectedImports)
+	}
+	if len(report.RemovedMarkers) != 1 || report.RemovedMarkers[0] != "data.regobrick.default_false" {
+		t.Errorf("expected removed markers [data.regobrick.default_false], got %v", report.RemovedMarkers)
+	}
+}
+
+func TestParseModuleWithOptionsReport_Direct(t *testing.T) {
+	_, report, err := regobrick.ParseModuleWithOptionsReport("legacy.rego", "package test\n\nallow { input.x }\n", regobrick.ParseOptions{
+		RegoVersion: ast.RegoV0,
+		Features:    []string{"default_false"},
+	})
+	if err != nil {
+		t.Fatalf("ParseModuleWithOptionsReport failed: %v", err)
+	}
+	want := regobrick.DefaultRule{Feature: "default_false", Ref: "allow", Value: "false"}
+	if len(report.DefaultRules) != 1 || report.DefaultRules[0] != want {
+		t.Errorf("expected default rules [%v], got %v", want, report.DefaultRules)
+	}
+}
+
+// TestModule_GeneratedDefaultErrorNamesOriginalRule checks that a compiler error
+// involving a synthesized default names the file and line of the rule it
+// defaults.
+func TestModule_GeneratedDefaultErrorNamesOriginalRule(t *testing.T) {
+	ctx := context.Background()
+
+	policy := `package test
+import data.regobrick.default_false
+
+allow if input.x
+`
+	other := `package test
+
+default allow := true
+`
+
+	_, err := rego.New(
+		regobrick.Module("a.rego", policy, nil),
+		rego.Module("z.rego", other),
+		rego.Query("data.test.allow"),
+	).PrepareForEval(ctx)
+	if err == nil {
+		t.Fatal("expected multiple default rules error, got nil")
+	}
+	if !strings.Contains(err.Error(), "a.rego:4") {
+		t.Errorf("error should point at the original rule a.rego:4, got: %v", err)
+	}
+
+	module, err := regobrick.ParseModule("a.rego", policy, nil)
+	if err != nil {
+		t.Fatalf("ParseModule failed: %v", err)
+	}
+	generated := 0
+	for _, rule := range module.Rules {
+		if feature, ok := regobrick.GeneratedBy(rule); ok {
+			generated++
+			if feature != "default_false" {
+				t.Errorf("expected default_false, got %q", feature)
+			}
+		}
+	}
+	if generated != 1 {
+		t.Errorf("expected 1 generated rule, got %d", generated)
+	}
+}
+
+func TestModuleSet_PublicAPI(t *testing.T) {
+	ctx := context.Background()
+
+	opts, err := regobrick.NewModuleSet().
+		Add("bad1.rego", "package bad1\nimport data.regobrick.nope\n", nil).
+		Add("good.rego", "package test\nimport data.regobrick.default_false\nallow if input.x\n", nil).
+		Add("bad2.rego", "package bad2\nallow = true { input.x }\n", []string{"data.lib.money"}).
+		Options()
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if opts != nil {
+		t.Errorf("expected no options on error, got %d", len(opts))
+	}
+	for _, name := range []string{`"bad1.rego"`, `"bad2.rego"`} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("error should name %s, got: %v", name, err)
+		}
+	}
+	var me *regobrick.ModuleError
+	if !errors.As(err, &me) || me.Filename != "bad1.rego" {
+		t.Errorf("expected *ModuleError for bad1.rego, got: %v", err)
+	}
+
+	opts, err = regobrick.NewModuleSet().
+		Add("good.rego", "package test\nimport data.regobrick.default_false\nallow if input.x\n", nil).
+		Options()
+	if err != nil {
+		t.Fatalf("Options failed: %v", err)
+	}
+	query, err := rego.New(append(opts, rego.Query("data.test.allow"))...).PrepareForEval(ctx)
+	if err != nil {
+		t.Fatalf("PrepareForEval failed: %v", err)
+	}
+	rs, err := query.Eval(ctx, rego.EvalInput(map[string]any{}))
+	if err != nil {
+		t.Fatalf("Eval failed: %v", err)
+	}
+	if len(rs) == 0 || rs[0].Expressions[0].Value != false {
+		t.Errorf("expected false, got %v", rs)
+	}
+}
+
+func TestModulesFS_PackageImportInjection(t *testing.T) {
+	ctx := context.Background()
+
+	fsys := fstest.MapFS{
+		"policies/billing/invoice.rego": {Data: []byte(`package app.billing.invoice
+import data.regobrick.default_false
+
+allow if money.positive(input.amount)
+`)},
+		"policies/lib/money.rego": {Data: []byte(`package lib.money
+positive(x) if x > 0
+`)},
+	}
+
+	query, err := rego.New(
+		regobrick.ModulesFS(fsys, "policies/**",
+			regobrick.ImportsForPackage("app.billing.*", "data.lib.money")),
+		rego.Query("data.app.billing.invoice.allow"),
+	).PrepareForEval(ctx)
+	if err != nil {
+		t.Fatalf("PrepareForEval failed: %v", err)
+	}
+
+	for _, tt := range []struct {
+		amount int
+		want   bool
+	}{{10, true}, {-1, false}} {
+		rs, err := query.Eval(ctx, rego.EvalInput(map[string]any{"amount": tt.amount}))
+		if err != nil {
+			t.Fatalf("Eval failed: %v", err)
+		}
+		if len(rs) == 0 || rs[0].Expressions[0].Value != tt.want {
+			t.Errorf("amount %d: expected %v, got %v", tt.amount, tt.want, rs)
+		}
+	}
+
+	set := regobrick.NewModuleSet()
+	if err := set.AddFS(fsys, "missing/**"); err == nil {
+		t.Error("expected AddFS error for a pattern matching no files, got nil")
+	}
+}
+
+func TestModules_RegoV0(t *testing.T) {
+	ctx := context.Background()
+
+	policy := `package legacy
+import data.regobrick.default_false
+
+allow { helper.is_admin(input.user) }
+`
+	helper := `package helper
+is_admin(u) if u == "admin"
+`
+
+	query, err := rego.New(
+		regobrick.Modules(
+			regobrick.ModuleOption{
+				Filename:    "legacy.rego",
+				Source:      policy,
+				Imports:     []string{"data.helper"},
+				RegoVersion: ast.RegoV0,
+			},
+			regobrick.ModuleOption{Filename: "helper.rego", Source: helper},
+		),
+		rego.Query("data.legacy.allow"),
+	).PrepareForEval(ctx)
+	if err != nil {
+		t.Fatalf("PrepareForEval failed: %v", err)
+	}
+
+	for user, want := range map[string]bool{"admin": true, "guest": false} {
+		rs, err := query.Eval(ctx, rego.EvalInput(map[string]any{"user": user}))
+		if err != nil {
+			t.Fatalf("Eval failed: %v", err)
+		}
+		if len(rs) == 0 || rs[0].Expressions[0].Value != want {
+			t.Errorf("user %s: expected %v, got %v", user, want, rs)
+		}
+	}
+}
+
+func TestModules_HostEnabledFeatures(t *testing.T) {
+	ctx := context.Background()
+
+	policy := `package tenant
+allow if input.user == "admin"
+`
+	query, err := rego.New(
+		rego.Strict(true),
+		regobrick.Modules(regobrick.ModuleOption{
+			Filename:      "tenant.rego",
+			Source:        policy,
+			Features:      []string{"default_false"},
+			ForbidMarkers: true,
+		}),
+		rego.Query("data.tenant.allow"),
+	).PrepareForEval(ctx)
+	if err != nil {
+		t.Fatalf("PrepareForEval failed: %v", err)
+	}
+	rs, err := query.Eval(ctx, rego.EvalInput(map[string]any{"user": "guest"}))
+	if err != nil {
+		t.Fatalf("Eval failed: %v", err)
+	}
+	if len(rs) == 0 || rs[0].Expressions[0].Value != false {
+		t.Errorf("expected false from host-enabled default_false, got %v", rs)
+	}
+
+	_, err = regobrick.NewModuleSet().
+		AddModules(regobrick.ModuleOption{
+			Filename:      "tenant.rego",
+			Source:        "package tenant\nimport data.regobrick.default_false\nallow if input.x\n",
+			ForbidMarkers: true,
+		}).
+		Options()
+	if err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("expected marker import to be rejected, got: %v", err)
+	}
+}
+
+func TestModule_DefaultFalseFunctions(t *testing.T) {
+	ctx := context.Background()
+
+	policy := `package test
+import data.regobrick.default_false_functions
+
+is_owner(user, res) if res.owner == user
+
+result := is_owner(input.user, input.resource)
+`
+	query, err := rego.New(
+		regobrick.Module("test.rego", policy, nil),
+		rego.Query("data.test.result"),
+	).PrepareForEval(ctx)
+	if err != nil {
+		t.Fatalf("PrepareForEval failed: %v", err)
+	}
+
+	for user, want := range map[string]bool{"alice": true, "bob": false} {
+		input := map[string]any{"user": user, "resource": map[string]any{"owner": "alice"}}
+		rs, err := query.Eval(ctx, rego.EvalInput(input))
+		if err != nil {
+			t.Fatalf("Eval failed: %v", err)
+		}
+		if len(rs) == 0 || rs[0].Expressions[0].Value != want {
+			t.Errorf("user %s: expected %v, got %v", user, want, rs)
+		}
+	}
+}
+
+func TestParseModule_DecimalLiterals(t *testing.T) {
+	policy := `package pricing
+import data.regobrick.decimal_literals
+
+tiny := input.amount * 1e-25
+`
+	_, err := regobrick.ParseModule("pricing.rego", policy, nil)
+	if err == nil || !strings.Contains(err.Error(), "pricing.rego:4") {
+		t.Fatalf("expected decimal_literals error at pricing.rego:4, got: %v", err)
+	}
+}
+
+func TestModules_Cache(t *testing.T) {
+	ctx := context.Background()
+	cache := regobrick.NewCache(10)
+
+	policy := `package test
+import data.regobrick.default_false
+
+allow if input.user == "admin"
+`
+	for i := 0; i < 3; i++ {
+		query, err := rego.New(
+			regobrick.Modules(regobrick.ModuleOption{Filename: "test.rego", Source: policy, Cache: cache}),
+			rego.Query("data.test.allow"),
+		).PrepareForEval(ctx)
+		if err != nil {
+			t.Fatalf("PrepareForEval failed: %v", err)
+		}
+		rs, err := query.Eval(ctx, rego.EvalInput(map[string]any{"user": "guest"}))
+		if err != nil {
+			t.Fatalf("Eval failed: %v", err)
+		}
+		if len(rs) == 0 || rs[0].Expressions[0].Value != false {
+			t.Errorf("run %d: expected false, got %v", i, rs)
+		}
+	}
+
+	if got := cache.Stats(); got.Misses != 1 || got.Hits != 2 {
+		t.Errorf("expected 1 miss and 2 hits, got %+v", got)
+	}
+}
+
+func TestModule_CollectReasons(t *testing.T) {
+	ctx := context.Background()
+
+	policy := `package app
+import data.regobrick.collect_reasons
+
+# METADATA
+# title: Orders need a customer
+deny contains "missing customer" if not input.customer
+`
+	rs, err := rego.New(
+		regobrick.Module("app.rego", policy, nil),
+		rego.Query("data.app.reasons"),
+		rego.Input(map[string]any{}),
+	).Eval(ctx)
+	if err != nil {
+		t.Fatalf("Eval failed: %v", err)
+	}
+	reasons, ok := rs[0].Expressions[0].Value.([]any)
+	if !ok || len(reasons) != 1 {
+		t.Fatalf("expected one reason, got %v", rs)
+	}
+	reason := reasons[0].(map[string]any)
+	if reason["msg"] != "missing customer" || reason["title"] != "Orders need a customer" || reason["rule"] != "data.app.deny" {
+		t.Errorf("unexpected reason: %v", reason)
+	}
+}
@@ -489,7 +489,9 @@ result := input.a + input.b
 	if err == nil {
 		t.Fatal("expected json.Marshal error due to leading zero, but got none")
 	}
-	if !strings.Contains(err.Error(), "invalid number literal") {
+	// Go 1.25 reports "invalid number literal"; releases whose encoding/json
+	// is backed by encoding/json/v2 report `cannot parse "01" as JSON number`.
+	if !strings.Contains(err.Error(), "invalid number literal") && !strings.Contains(err.Error(), "as JSON number") {
 		t.Errorf("expected a json.Marshal invalid-number-literal error, got: %v", err)
 	}
 	if !strings.Contains(err.Error(), "01") {
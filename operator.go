@@ -3,13 +3,19 @@ package regobrick
 import (
 	"encoding/json"
 	"errors"
-	"strconv"
-	"strings"
+	"fmt"
+	"math/big"
+	"slices"
+	"sync"
+	"sync/atomic"
 
 	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/open-policy-agent/opa/v1/rego"
 	"github.com/open-policy-agent/opa/v1/topdown"
 	"github.com/open-policy-agent/opa/v1/topdown/builtins"
+	"github.com/open-policy-agent/opa/v1/types"
 	"github.com/quagmt/udecimal"
+	"github.com/sky1core/regobrick/internal/decimal"
 )
 
 // ------------------------------------------------------------
@@ -18,9 +24,33 @@ import (
 
 type decimalArithmeticConfig struct {
 	stringCoercion bool
+	// rounding is the mode set by WithRoundingMode, or zero for the default:
+	// truncating division and multiplication, round() half away from zero.
+	rounding RoundingMode
+	// scale is the number of fractional digits set by WithDivisionScale, if
+	// hasScale is true.
+	scale    int
+	hasScale bool
+	// big selects BigDecimalBackend, with precision fractional digits for
+	// inexact results; see WithBackend.
+	big       bool
+	precision int
 }
 
-var decimalConfig decimalArithmeticConfig
+// decimalConfig is the configuration of the process-global decimal mode, or nil
+// once RestoreStandardArithmetic has switched it off. It is swapped as a whole,
+// never modified in place.
+var decimalConfig atomic.Pointer[decimalArithmeticConfig]
+
+// decimalRegistry tracks the operators UseDecimalArithmetic installs in OPA's
+// builtin registry. standard holds OPA's implementations of decimal.Operators,
+// captured before the first installation, for RestoreStandardArithmetic to put
+// back; div is the declaration of decimal.div while it is registered.
+var decimalRegistry struct {
+	sync.Mutex
+	standard map[string]topdown.BuiltinFunc
+	div      *ast.Builtin
+}
 
 // DecimalArithmeticOption configures UseDecimalArithmetic behavior.
 type DecimalArithmeticOption func(*decimalArithmeticConfig)
@@ -48,6 +78,79 @@ func WithStringCoercion() DecimalArithmeticOption {
 	}
 }
 
+// RoundingMode selects how WithRoundingMode rounds inexact results.
+type RoundingMode = decimal.RoundingMode
+
+// Rounding modes for WithRoundingMode. "Up" and "down" are away from and toward
+// zero, as in java.math.RoundingMode.
+const (
+	// RoundDown rounds toward zero (truncation).
+	RoundDown = decimal.RoundDown
+	// RoundUp rounds away from zero.
+	RoundUp = decimal.RoundUp
+	// RoundCeiling rounds toward positive infinity.
+	RoundCeiling = decimal.RoundCeiling
+	// RoundFloor rounds toward negative infinity.
+	RoundFloor = decimal.RoundFloor
+	// RoundHalfUp rounds to the nearest neighbor, ties away from zero.
+	RoundHalfUp = decimal.RoundHalfUp
+	// RoundHalfDown rounds to the nearest neighbor, ties toward zero.
+	RoundHalfDown = decimal.RoundHalfDown
+	// RoundHalfEven rounds to the nearest neighbor, ties to the even neighbor
+	// (banker's rounding).
+	RoundHalfEven = decimal.RoundHalfEven
+)
+
+// WithRoundingMode rounds every inexact result with mode instead of the default
+// truncation:
+//
+//   - / : the quotient, to 19 decimal places (or WithDivisionScale, or the
+//     precision of BigDecimalBackend)
+//   - * : the product, when it has more decimal places than that
+//   - product(): the exact product of all elements, rounded once
+//   - round(): to an integer (the default is half away from zero)
+//
+// Other operations are exact (+, -, %, abs, sum) or round in a fixed direction
+// by definition (ceil, floor).
+//
+// For example, with RoundHalfEven 2 / 3 is 0.6666666666666666667 instead of
+// 0.6666666666666666666, and round(2.5) is 2 instead of 3.
+//
+// Rounded division and multiplication compute the exact result with math/big
+// before rounding, which is slower than the default truncation. It panics if
+// mode is not one of the declared rounding modes.
+func WithRoundingMode(mode RoundingMode) DecimalArithmeticOption {
+	if !mode.Valid() {
+		panic(fmt.Sprintf("regobrick: invalid rounding mode %v", mode))
+	}
+	return func(cfg *decimalArithmeticConfig) {
+		cfg.rounding = mode
+	}
+}
+
+// WithDivisionScale caps the fractional digits of inexact results at scale
+// instead of 19 (or the precision of BigDecimalBackend): the results of /, *
+// and product(). They are rounded with the
+// mode of WithRoundingMode, or truncated by default. With scale 2, 100 / 3 is
+// 33.33 and 1.005 * 3 is 3.01; trailing zeros are dropped, so 10 / 4 is 2.5.
+//
+// +, -, %, abs, sum and the comparisons are exact and not capped; round, ceil
+// and floor return integers. The builtin decimal.div(x, y, scale) divides with
+// an explicit scale regardless of this option.
+//
+// It panics if scale is negative. UseDecimalArithmetic and DecimalArithmetic
+// panic if scale exceeds the fractional digits of the backend: 19 for
+// UDecimalBackend, the precision of BigDecimalBackend.
+func WithDivisionScale(scale int) DecimalArithmeticOption {
+	if scale < 0 {
+		panic(fmt.Sprintf("regobrick: negative division scale %d", scale))
+	}
+	return func(cfg *decimalArithmeticConfig) {
+		cfg.scale = scale
+		cfg.hasScale = true
+	}
+}
+
 // UseDecimalArithmetic replaces Rego's numeric operations with precision decimal operations.
 //
 // # Overloaded operators
@@ -71,7 +174,8 @@ func WithStringCoercion() DecimalArithmeticOption {
 //
 //   - Maximum 19 decimal places; values with more fail to parse
 //     (default mode: no result; StrictBuiltinErrors: eval error).
-//     Truncation (not rounding) applies only to operation results, e.g. 100/3.
+//     Truncation (not rounding) applies only to operation results, e.g. 100/3,
+//     unless WithRoundingMode is set.
 //   - Magnitude: coefficients up to 128 bits
 //     (±34,028,236,692,093,846,346.3374607431768211455 at the full 19 decimal
 //     places) stay on udecimal's zero-allocation fast path; larger plain-notation
@@ -81,6 +185,10 @@ func WithStringCoercion() DecimalArithmeticOption {
 //     1e100) fail, while the same magnitude written out in plain notation
 //     parses fine.
 //
+// WithBackend(BigDecimalBackend{}) lifts these limits: any number of
+// fractional digits and exponents up to ±1000, at the cost of allocating on
+// every operation.
+//
 // # Error handling
 //
 //   - Default mode: operation failure results in rule not satisfied (no result)
@@ -89,6 +197,21 @@ func WithStringCoercion() DecimalArithmeticOption {
 // # Options
 //
 //   - WithStringCoercion(): auto-convert numeric strings to numbers
+//   - WithRoundingMode(mode): round inexact results instead of truncating them
+//   - WithDivisionScale(n): cap the fractional digits of inexact results at n
+//   - WithBackend(backend): compute with udecimal (default) or math/big
+//
+// # decimal.div
+//
+// UseDecimalArithmetic also registers decimal.div(x, y, scale), which returns
+// x / y with at most scale (0 to 19, or the precision of BigDecimalBackend)
+// fractional digits, rounded with the mode of
+// WithRoundingMode or truncated by default:
+//
+//	decimal.div(100, 3, 2)  # 33.33
+//
+// RestoreStandardArithmetic unregisters it again, leaving ast.Builtins and the
+// capabilities of OPA as they were.
 //
 // # Usage
 //
@@ -98,176 +221,258 @@ func WithStringCoercion() DecimalArithmeticOption {
 //	// With string-to-number coercion
 //	regobrick.UseDecimalArithmetic(regobrick.WithStringCoercion())
 //
-// Call this once at application startup, before any evaluation begins.
-// It mutates process-global state (OPA's builtin registry and the coercion
-// setting) without synchronization, so it is not safe to call concurrently
-// with evaluations.
+// Every Rego evaluation in the process is affected, including those of other
+// components embedding OPA; use DecimalArithmetic to enable decimal semantics
+// for selected queries only.
+//
+// # Reconfiguration
+//
+// The first call, and the first call after RestoreStandardArithmetic, replaces
+// the overloaded operators in OPA's builtin registry, which is not
+// synchronized: make it at application startup, before any evaluation begins.
+// Later calls only swap the configuration atomically and are safe concurrently
+// with evaluations. Each evaluation reads the configuration once, on its first
+// overloaded operator, and keeps it to the end, so a query never mixes two
+// configurations.
 func UseDecimalArithmetic(opts ...DecimalArithmeticOption) {
+	cfg := newDecimalArithmeticConfig(opts)
+	decimalConfig.Store(&cfg)
+	registerDecimalBuiltins()
+}
+
+// RestoreStandardArithmetic puts OPA's original implementations of the
+// operators overloaded by UseDecimalArithmetic back into OPA's builtin
+// registry, e.g. at the end of a test or when a feature flag is turned off, and
+// unregisters decimal.div. It has no effect if decimal arithmetic is not
+// enabled. A later UseDecimalArithmetic call enables decimal arithmetic again.
+//
+// Like the first UseDecimalArithmetic call, RestoreStandardArithmetic writes
+// the unsynchronized registry: do not call it while evaluations or
+// compilations are running. To change the configuration while serving
+// queries, call UseDecimalArithmetic with other options instead.
+func RestoreStandardArithmetic() {
+	decimalConfig.Store(nil)
+
+	decimalRegistry.Lock()
+	defer decimalRegistry.Unlock()
+	div := decimalRegistry.div
+	if div == nil {
+		return
+	}
+	for _, name := range decimal.Operators {
+		topdown.RegisterBuiltinFunc(name, decimalRegistry.standard[name])
+	}
+
+	// OPA has no way to unregister a builtin: drop decimal.div from the
+	// declarations, so that ast.Builtins and the capabilities derived from it
+	// are as before and policies calling it no longer compile. Its topdown
+	// implementation stays, unreachable.
+	ast.Builtins = slices.DeleteFunc(slices.Clone(ast.Builtins), func(b *ast.Builtin) bool { return b == div })
+	delete(ast.BuiltinMap, decimal.Div)
+	decimalRegistry.div = nil
+}
+
+func newDecimalArithmeticConfig(opts []DecimalArithmeticOption) decimalArithmeticConfig {
 	cfg := decimalArithmeticConfig{}
 	for _, opt := range opts {
 		opt(&cfg)
 	}
-	decimalConfig = cfg
-	registerDecimalBuiltins()
+	if cfg.hasScale && cfg.scale > cfg.maxScale() {
+		panic(fmt.Sprintf("regobrick: division scale %d exceeds the %d fractional digits of the decimal backend", cfg.scale, cfg.maxScale()))
+	}
+	return cfg
 }
 
-func registerDecimalBuiltins() {
+// decimalOperatorFunc is the implementation of an overloaded operator under a
+// given configuration.
+type decimalOperatorFunc func(*decimalArithmeticConfig, topdown.BuiltinContext, []*ast.Term, func(*ast.Term) error) error
+
+// decimalOperators maps the name of every builtin in decimal.Operators to its
+// decimal implementation.
+var decimalOperators = map[string]decimalOperatorFunc{
 	// Arithmetic operators
-	topdown.RegisterBuiltinFunc(ast.Plus.Name, precisionPlus)
-	topdown.RegisterBuiltinFunc(ast.Minus.Name, precisionMinus)
-	topdown.RegisterBuiltinFunc(ast.Multiply.Name, precisionMultiply)
-	topdown.RegisterBuiltinFunc(ast.Divide.Name, precisionDivide)
-	topdown.RegisterBuiltinFunc(ast.Rem.Name, precisionRem)
+	ast.Plus.Name:     (*decimalArithmeticConfig).plus,
+	ast.Minus.Name:    (*decimalArithmeticConfig).minus,
+	ast.Multiply.Name: (*decimalArithmeticConfig).multiply,
+	ast.Divide.Name:   (*decimalArithmeticConfig).divide,
+	ast.Rem.Name:      (*decimalArithmeticConfig).rem,
 
 	// Comparison operators
-	topdown.RegisterBuiltinFunc(ast.GreaterThan.Name, precisionGT)
-	topdown.RegisterBuiltinFunc(ast.GreaterThanEq.Name, precisionGTE)
-	topdown.RegisterBuiltinFunc(ast.LessThan.Name, precisionLT)
-	topdown.RegisterBuiltinFunc(ast.LessThanEq.Name, precisionLTE)
-	topdown.RegisterBuiltinFunc(ast.Equal.Name, precisionEqual)
-	topdown.RegisterBuiltinFunc(ast.NotEqual.Name, precisionNotEqual)
+	ast.GreaterThan.Name:   (*decimalArithmeticConfig).gt,
+	ast.GreaterThanEq.Name: (*decimalArithmeticConfig).gte,
+	ast.LessThan.Name:      (*decimalArithmeticConfig).lt,
+	ast.LessThanEq.Name:    (*decimalArithmeticConfig).lte,
+	ast.Equal.Name:         (*decimalArithmeticConfig).equal,
+	ast.NotEqual.Name:      (*decimalArithmeticConfig).notEqual,
 
 	// Unary operators
-	topdown.RegisterBuiltinFunc(ast.Abs.Name, precisionAbs)
-	topdown.RegisterBuiltinFunc(ast.Round.Name, precisionRound)
-	topdown.RegisterBuiltinFunc(ast.Ceil.Name, precisionCeil)
-	topdown.RegisterBuiltinFunc(ast.Floor.Name, precisionFloor)
+	ast.Abs.Name:   (*decimalArithmeticConfig).abs,
+	ast.Round.Name: (*decimalArithmeticConfig).round,
+	ast.Ceil.Name:  (*decimalArithmeticConfig).ceil,
+	ast.Floor.Name: (*decimalArithmeticConfig).floor,
 
 	// Aggregate operators
-	topdown.RegisterBuiltinFunc(ast.Sum.Name, precisionSum)
-	topdown.RegisterBuiltinFunc(ast.Product.Name, precisionProduct)
-	topdown.RegisterBuiltinFunc(ast.Max.Name, precisionMax)
-	topdown.RegisterBuiltinFunc(ast.Min.Name, precisionMin)
+	ast.Sum.Name:     (*decimalArithmeticConfig).sum,
+	ast.Product.Name: (*decimalArithmeticConfig).product,
+	ast.Max.Name:     (*decimalArithmeticConfig).max,
+	ast.Min.Name:     (*decimalArithmeticConfig).min,
 }
 
-// maxExpandedLen is the upper bound on the string length of an expanded exponent
-// notation result. It is an allocation guard, not a udecimal limit: without it a
-// huge exponent from an input path (e.g. 1e2000000000) would trigger ~2GB of
-// zero-string allocation via strings.Repeat. When the bound is exceeded the
-// original string is returned unchanged and udecimal.Parse rejects the exponent
-// notation as an invalid format.
-//
-// Consequence: exponent-notation inputs are capped at a ~62-digit expansion
-// (1e61 parses; 1e62 and beyond, e.g. 1e100, fail) even though udecimal itself
-// could represent them via its big.Int fallback. The same magnitude written in
-// plain notation is unaffected by this cap. The bound comfortably covers the
-// u128 fast path (~39 significant digits, up to 19 of them fractional).
-const maxExpandedLen = 64
-
-// expandExponent expands a scientific-notation number string into plain decimal
-// notation. E.g. "1e-8"→"0.00000001", "-2.5E+3"→"-2500", "1.5e0"→"1.5".
-//
-// It shifts only the decimal point via pure string manipulation without going
-// through float64, so there is no precision loss. Non-exponent (e.g. "123.45")
-// or malformed strings are returned unchanged for udecimal.Parse to decide on.
-//
-// When the expanded result exceeds udecimal's precision (19 fractional digits,
-// e.g. "1e-25"→"0.0000...1"), udecimal.Parse returns an error; this is the
-// documented precision limit. An exponent whose expansion would exceed
-// maxExpandedLen (e.g. "1e100", "1e2000000000") is not expanded and the
-// original string is returned to avoid an allocation blowup; udecimal then
-// rejects the un-expanded exponent notation as an invalid format.
-func expandExponent(s string) string {
-	ePos := strings.IndexAny(s, "eE")
-	if ePos < 0 {
-		return s
+// registerDecimalBuiltins replaces every builtin in decimal.Operators with an
+// operator dispatching on the process-global configuration, and registers
+// decimal.div, unless they are installed already. OPA's implementations are
+// captured once, before the first replacement, so that restoring and enabling
+// decimal arithmetic repeatedly does not wrap them again and again.
+func registerDecimalBuiltins() {
+	decimalRegistry.Lock()
+	defer decimalRegistry.Unlock()
+	if decimalRegistry.div != nil {
+		return
 	}
-
-	mantissa := s[:ePos]
-	exp, err := strconv.Atoi(s[ePos+1:])
-	if err != nil {
-		return s // exponent is not an integer (including out-of-int-range) → let udecimal reject it
+	if decimalRegistry.standard == nil {
+		decimalRegistry.standard = make(map[string]topdown.BuiltinFunc, len(decimal.Operators))
+		for _, name := range decimal.Operators {
+			decimalRegistry.standard[name] = topdown.GetBuiltin(name)
+		}
 	}
-
-	// Size guard 1: if the absolute exponent exceeds the bound, the expansion is
-	// guaranteed to exceed it too, so do not expand. (This also blocks integer
-	// overflow in the later newExp computation.)
-	if exp > maxExpandedLen || exp < -maxExpandedLen {
-		return s
+	for _, name := range decimal.Operators {
+		topdown.RegisterBuiltinFunc(name, globalDecimalOperator(decimalOperators[name], decimalRegistry.standard[name]))
 	}
 
-	// Separate the sign of the mantissa.
-	sign := ""
-	if len(mantissa) > 0 && (mantissa[0] == '+' || mantissa[0] == '-') {
-		if mantissa[0] == '-' {
-			sign = "-"
+	// Share the categories of "/" so FilterCapabilities keeps both together.
+	decimalRegistry.div = &ast.Builtin{Name: decimal.Div, Decl: decimal.DivDecl, Categories: ast.Divide.Categories}
+	ast.RegisterBuiltin(decimalRegistry.div)
+	divScale := (*decimalArithmeticConfig).divScale
+	topdown.RegisterBuiltinFunc(decimal.Div, globalDecimalOperator(divScale, func(bctx topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
+		// decimal.div is no standard operator: keep it working in standard mode.
+		return divScale(&decimalArithmeticConfig{}, bctx, operands, iter)
+	}))
+}
+
+// globalDecimalOperator binds op to the process-global configuration set by
+// UseDecimalArithmetic, and calls standard, OPA's implementation of the same
+// builtin, while no configuration is set.
+func globalDecimalOperator(op decimalOperatorFunc, standard topdown.BuiltinFunc) topdown.BuiltinFunc {
+	return func(bctx topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
+		cfg := evalDecimalConfig(bctx)
+		if cfg == nil {
+			return standard(bctx, operands, iter)
 		}
-		mantissa = mantissa[1:]
+		return op(cfg, bctx, operands, iter)
 	}
+}
 
-	// Separate the integer and fractional parts.
-	intPart := mantissa
-	fracPart := ""
-	if dot := strings.IndexByte(mantissa, '.'); dot >= 0 {
-		intPart = mantissa[:dot]
-		fracPart = mantissa[dot+1:]
+// decimalConfigCacheKey keys the configuration snapshot of an evaluation in its
+// builtin cache.
+type decimalConfigCacheKey struct{}
+
+// evalDecimalConfig returns the process-global configuration as of the first
+// overloaded operator of the evaluation bctx belongs to, or nil in standard
+// mode. The snapshot lives in the evaluation's builtin cache, which OPA creates
+// per evaluation and never shares between goroutines.
+func evalDecimalConfig(bctx topdown.BuiltinContext) *decimalArithmeticConfig {
+	if bctx.Cache == nil {
+		return decimalConfig.Load()
 	}
-
-	digits := intPart + fracPart
-	if digits == "" {
-		return s
+	if v, ok := bctx.Cache.Get(decimalConfigCacheKey{}); ok {
+		return v.(*decimalArithmeticConfig)
 	}
-	for i := 0; i < len(digits); i++ {
-		if digits[i] < '0' || digits[i] > '9' {
-			return s // non-digit character in the mantissa → let udecimal reject it
-		}
-	}
-
-	// value = digits × 10^(exp - len(fracPart))
-	newExp := exp - len(fracPart)
+	cfg := decimalConfig.Load()
+	bctx.Cache.Put(decimalConfigCacheKey{}, cfg)
+	return cfg
+}
 
-	// Size guard 2: precompute the expansion length and do not expand if it
-	// exceeds the bound.
-	// (newExp >= 0: len(digits)+newExp, newExp < 0: at most len(digits)+|newExp|+2)
-	absNewExp := newExp
-	if absNewExp < 0 {
-		absNewExp = -absNewExp
+// DecimalArithmetic returns a rego.Rego option that enables decimal arithmetic
+// for one query only, without touching OPA's global builtin registry. Standard
+// and decimal policies can therefore coexist in one process, including in
+// third-party components that embed OPA.
+//
+// The option registers, for this query, one "regobrick.decimal.<op>" builtin per
+// overloaded operator (e.g. regobrick.decimal.plus), with the same declaration
+// and the same semantics as under UseDecimalArithmetic(opts...). The modules opt
+// in with the "decimal_arithmetic" feature, which rewrites their operator calls
+// (a + b, a > b, sum(xs), ...) to those builtins at parse time, as well as calls
+// to decimal.div (to regobrick.decimal.decimal.div):
+//
+//	rego.New(
+//	    regobrick.DecimalArithmetic(regobrick.WithStringCoercion()),
+//	    regobrick.Module("pricing.rego", src, []string{"data.regobrick.decimal_arithmetic"}),
+//	    rego.Query("data.pricing.total"),
+//	)
+//
+// The feature can also be enabled in the source ("import
+// data.regobrick.decimal_arithmetic") or by the host (ParseOptions.Features).
+// Modules without it, and the query string itself, keep OPA's standard
+// operators. A rewritten module in a query without DecimalArithmetic fails to
+// compile with an undefined function error rather than silently using standard
+// arithmetic.
+//
+// Errors of the scoped builtins (e.g. division by zero) are reported like those
+// of other custom functions, subject to rego.StrictBuiltinErrors.
+func DecimalArithmetic(opts ...DecimalArithmeticOption) func(*rego.Rego) {
+	cfg := newDecimalArithmeticConfig(opts)
+	functions := make([]func(*rego.Rego), 0, len(decimal.Operators)+1)
+	add := func(name string, decl *types.Function, op decimalOperatorFunc) {
+		functions = append(functions, rego.FunctionDyn(&rego.Function{
+			Name: decimal.ScopedName(name),
+			Decl: decl,
+		}, func(bctx rego.BuiltinContext, operands []*ast.Term) (*ast.Term, error) {
+			var result *ast.Term
+			err := op(&cfg, bctx, operands, func(t *ast.Term) error {
+				result = t
+				return nil
+			})
+			return result, err
+		}))
 	}
-	if len(digits)+absNewExp+2 > maxExpandedLen {
-		return s
+	for _, name := range decimal.Operators {
+		add(name, ast.BuiltinMap[name].Decl, decimalOperators[name])
 	}
-
-	var out string
-	if newExp >= 0 {
-		out = digits + strings.Repeat("0", newExp)
-	} else {
-		k := -newExp
-		if len(digits) > k {
-			out = digits[:len(digits)-k] + "." + digits[len(digits)-k:]
-		} else {
-			out = "0." + strings.Repeat("0", k-len(digits)) + digits
+	add(decimal.Div, decimal.DivDecl, (*decimalArithmeticConfig).divScale)
+	return func(r *rego.Rego) {
+		for _, fn := range functions {
+			fn(r)
 		}
 	}
-	return sign + out
 }
 
-// parseDecimal parses a numeric string into a udecimal.Decimal.
-// It first expands exponent notation into plain decimal notation before passing
-// it to udecimal.Parse, so exponent notation like "1e-8" is parsed accurately.
+// maxExpandedLen is the upper bound on the string length of an expanded exponent
+// notation result; see decimal.MaxExpandedLen.
+const maxExpandedLen = decimal.MaxExpandedLen
+
+// expandExponent expands a scientific-notation number string into plain decimal
+// notation; see decimal.ExpandExponent.
+func expandExponent(s string) string {
+	return decimal.ExpandExponent(s)
+}
+
+// parseDecimal parses a numeric string into a udecimal.Decimal; see
+// decimal.Parse. It is shared with the decimal_literals feature of ParseModule,
+// so a literal accepted there is also accepted by the operators.
 func parseDecimal(s string) (udecimal.Decimal, error) {
-	return udecimal.Parse(expandExponent(s))
+	return decimal.Parse(s)
 }
 
-// toDecimal converts an ast.Value into a udecimal.Decimal.
+// toNumber converts an ast.Value into a number.
 // It attempts to parse both ast.Number and ast.String, returning false on
 // failure. As a low-level helper, the stringCoercion condition is decided by the
 // caller (isNumericType, etc.).
-func toDecimal(v ast.Value) (udecimal.Decimal, bool) {
+func (cfg *decimalArithmeticConfig) toNumber(v ast.Value) (number, bool) {
 	switch val := v.(type) {
 	case ast.Number:
-		d, err := parseDecimal(string(val))
+		d, err := cfg.parseNumber(string(val))
 		if err != nil {
-			return udecimal.Decimal{}, false
+			return number{}, false
 		}
 		return d, true
 	case ast.String:
-		d, err := parseDecimal(string(val))
+		d, err := cfg.parseNumber(string(val))
 		if err != nil {
-			return udecimal.Decimal{}, false
+			return number{}, false
 		}
 		return d, true
 	default:
-		return udecimal.Decimal{}, false
+		return number{}, false
 	}
 }
 
@@ -275,89 +480,89 @@ func toDecimal(v ast.Value) (udecimal.Decimal, bool) {
 // ast.Number is always true (it is a numeric type even if parsing fails).
 // ast.String is true only when stringCoercion is enabled and it parses as a
 // number.
-func isNumericType(v ast.Value) bool {
+func (cfg *decimalArithmeticConfig) isNumericType(v ast.Value) bool {
 	switch v.(type) {
 	case ast.Number:
 		return true
 	case ast.String:
-		if !decimalConfig.stringCoercion {
+		if !cfg.stringCoercion {
 			return false
 		}
-		_, ok := toDecimal(v)
+		_, ok := cfg.toNumber(v)
 		return ok
 	default:
 		return false
 	}
 }
 
-// operandToDecimal converts an operator operand into a udecimal.Decimal.
+// operandToDecimal converts an operator operand into a number.
 // A parse error on an ast.Number (e.g. the out-of-precision "1e-25") is returned
 // as-is so that it becomes an eval_builtin_error.
 // An ast.String is converted only when stringCoercion is enabled.
-func operandToDecimal(v ast.Value, pos int) (udecimal.Decimal, error) {
+func (cfg *decimalArithmeticConfig) operandToDecimal(v ast.Value, pos int) (number, error) {
 	switch val := v.(type) {
 	case ast.Number:
-		d, err := parseDecimal(string(val))
+		d, err := cfg.parseNumber(string(val))
 		if err != nil {
-			return udecimal.Decimal{}, err
+			return number{}, err
 		}
 		return d, nil
 	case ast.String:
-		if !decimalConfig.stringCoercion {
-			return udecimal.Decimal{}, builtins.NewOperandTypeErr(pos, v, "number")
+		if !cfg.stringCoercion {
+			return number{}, builtins.NewOperandTypeErr(pos, v, "number")
 		}
-		d, err := parseDecimal(string(val))
+		d, err := cfg.parseNumber(string(val))
 		if err != nil {
-			return udecimal.Decimal{}, builtins.NewOperandTypeErr(pos, v, "number")
+			return number{}, builtins.NewOperandTypeErr(pos, v, "number")
 		}
 		return d, nil
 	default:
-		return udecimal.Decimal{}, builtins.NewOperandTypeErr(pos, v, "number")
+		return number{}, builtins.NewOperandTypeErr(pos, v, "number")
 	}
 }
 
-// elementToDecimal converts an array/set element into a udecimal.Decimal.
+// elementToDecimal converts an array/set element into a number.
 // A parse error on an ast.Number is returned as-is.
 // An ast.String is converted only when stringCoercion is enabled.
-func elementToDecimal(container ast.Value, elem *ast.Term) (udecimal.Decimal, error) {
+func (cfg *decimalArithmeticConfig) elementToDecimal(container ast.Value, elem *ast.Term) (number, error) {
 	switch val := elem.Value.(type) {
 	case ast.Number:
-		d, err := parseDecimal(string(val))
+		d, err := cfg.parseNumber(string(val))
 		if err != nil {
-			return udecimal.Decimal{}, err
+			return number{}, err
 		}
 		return d, nil
 	case ast.String:
-		if !decimalConfig.stringCoercion {
-			return udecimal.Decimal{}, builtins.NewOperandElementErr(1, container, elem.Value, "number")
+		if !cfg.stringCoercion {
+			return number{}, builtins.NewOperandElementErr(1, container, elem.Value, "number")
 		}
-		d, err := parseDecimal(string(val))
+		d, err := cfg.parseNumber(string(val))
 		if err != nil {
-			return udecimal.Decimal{}, builtins.NewOperandElementErr(1, container, elem.Value, "number")
+			return number{}, builtins.NewOperandElementErr(1, container, elem.Value, "number")
 		}
 		return d, nil
 	default:
-		return udecimal.Decimal{}, builtins.NewOperandElementErr(1, container, elem.Value, "number")
+		return number{}, builtins.NewOperandElementErr(1, container, elem.Value, "number")
 	}
 }
 
-// parseOperands parses two operands into udecimal.Decimal values.
+// parseOperands parses two operands into numbers.
 // When stringCoercion is enabled, numeric-format strings are auto-converted.
-func parseOperands(operands []*ast.Term) (udecimal.Decimal, udecimal.Decimal, error) {
-	d1, err := operandToDecimal(operands[0].Value, 1)
+func (cfg *decimalArithmeticConfig) parseOperands(operands []*ast.Term) (number, number, error) {
+	d1, err := cfg.operandToDecimal(operands[0].Value, 1)
 	if err != nil {
-		return udecimal.Decimal{}, udecimal.Decimal{}, err
+		return number{}, number{}, err
 	}
-	d2, err := operandToDecimal(operands[1].Value, 2)
+	d2, err := cfg.operandToDecimal(operands[1].Value, 2)
 	if err != nil {
-		return udecimal.Decimal{}, udecimal.Decimal{}, err
+		return number{}, number{}, err
 	}
 	return d1, d2, nil
 }
 
-// numberResult converts a udecimal result into an ast.Term.
-func numberResult(d udecimal.Decimal, iter func(*ast.Term) error) error {
-	return iter(ast.NumberTerm(json.Number(d.String())))
+// numberResult converts a result into an ast.Term.
+func numberResult(n number, iter func(*ast.Term) error) error {
+	return iter(ast.NumberTerm(json.Number(n.String())))
 }
 
 // boolResult converts a bool result into an ast.Term.
@@ -367,33 +572,33 @@ func boolResult(b bool, iter func(*ast.Term) error) error {
 
 // === Arithmetic operations ===
 
-func precisionPlus(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
-	d1, d2, err := parseOperands(operands)
+func (cfg *decimalArithmeticConfig) plus(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
+	d1, d2, err := cfg.parseOperands(operands)
 	if err != nil {
 		return err
 	}
-	return numberResult(d1.Add(d2), iter)
+	return numberResult(d1.add(d2), iter)
 }
 
-func precisionMinus(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
+func (cfg *decimalArithmeticConfig) minus(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
 	// minus is used for sets as well as numbers, so fall back to the original
 	// behavior when the operands are not numeric.
 	// When stringCoercion is enabled, numeric-format strings are treated as numbers.
-	numLike1 := isNumericType(operands[0].Value)
-	numLike2 := isNumericType(operands[1].Value)
+	numLike1 := cfg.isNumericType(operands[0].Value)
+	numLike2 := cfg.isNumericType(operands[1].Value)
 
 	if numLike1 && numLike2 {
 		// Parse via operandToDecimal to preserve ast.Number parse errors (e.g. the
 		// out-of-precision "1e-25").
-		d1, err := operandToDecimal(operands[0].Value, 1)
+		d1, err := cfg.operandToDecimal(operands[0].Value, 1)
 		if err != nil {
 			return err
 		}
-		d2, err := operandToDecimal(operands[1].Value, 2)
+		d2, err := cfg.operandToDecimal(operands[1].Value, 2)
 		if err != nil {
 			return err
 		}
-		return numberResult(d1.Sub(d2), iter)
+		return numberResult(d1.sub(d2), iter)
 	}
 
 	// Original behavior for set operations.
@@ -415,20 +620,24 @@ func precisionMinus(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*a
 	return builtins.NewOperandTypeErr(1, operands[0].Value, "number", "set")
 }
 
-func precisionMultiply(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
-	d1, d2, err := parseOperands(operands)
+func (cfg *decimalArithmeticConfig) multiply(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
+	d1, d2, err := cfg.parseOperands(operands)
 	if err != nil {
 		return err
 	}
-	return numberResult(d1.Mul(d2), iter)
+	result, err := cfg.mul(d1, d2)
+	if err != nil {
+		return err
+	}
+	return numberResult(result, iter)
 }
 
-func precisionDivide(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
-	d1, d2, err := parseOperands(operands)
+func (cfg *decimalArithmeticConfig) divide(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
+	d1, d2, err := cfg.parseOperands(operands)
 	if err != nil {
 		return err
 	}
-	result, err := d1.Div(d2)
+	result, err := cfg.quo(d1, d2)
 	if err != nil {
 		if errors.Is(err, udecimal.ErrDivideByZero) {
 			// Return a plain error like standard OPA so it is handled with the
@@ -441,75 +650,159 @@ func precisionDivide(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*
 	return numberResult(result, iter)
 }
 
+// truncates reports whether inexact results keep udecimal's truncation to 19
+// decimal places, the default without WithRoundingMode, WithDivisionScale and
+// BigDecimalBackend.
+func (cfg *decimalArithmeticConfig) truncates() bool {
+	return !cfg.big && cfg.rounding == 0 && !cfg.hasScale
+}
+
+// resultScale returns the number of fractional digits of inexact results.
+func (cfg *decimalArithmeticConfig) resultScale() int {
+	if cfg.hasScale {
+		return cfg.scale
+	}
+	return cfg.maxScale()
+}
+
+// roundingMode returns the mode inexact results are rounded with.
+func (cfg *decimalArithmeticConfig) roundingMode() RoundingMode {
+	if cfg.rounding == 0 {
+		return RoundDown
+	}
+	return cfg.rounding
+}
+
+// roundRat rounds the exact result r to scale fractional digits with mode and
+// converts it to a number of the backend.
+func (cfg *decimalArithmeticConfig) roundRat(r *big.Rat, scale int, mode RoundingMode) (number, error) {
+	rounded := decimal.RoundRat(r, scale, mode)
+	if cfg.big {
+		return number{r: rounded}, nil
+	}
+	d, err := decimal.FromRat(rounded)
+	if err != nil {
+		return number{}, err
+	}
+	return number{d: d}, nil
+}
+
+// mul returns a * b, truncated to 19 decimal places unless a rounding mode, a
+// scale or BigDecimalBackend is set.
+func (cfg *decimalArithmeticConfig) mul(a, b number) (number, error) {
+	if cfg.truncates() {
+		return number{d: a.d.Mul(b.d)}, nil
+	}
+	return cfg.roundRat(new(big.Rat).Mul(a.rat(), b.rat()), cfg.resultScale(), cfg.roundingMode())
+}
+
+// quo returns a / b, truncated to 19 decimal places unless a rounding mode, a
+// scale or BigDecimalBackend is set. It returns udecimal.ErrDivideByZero if b is
+// zero.
+func (cfg *decimalArithmeticConfig) quo(a, b number) (number, error) {
+	if cfg.truncates() {
+		d, err := a.d.Div(b.d)
+		return number{d: d}, err
+	}
+	if b.isZero() {
+		return number{}, udecimal.ErrDivideByZero
+	}
+	return cfg.roundRat(new(big.Rat).Quo(a.rat(), b.rat()), cfg.resultScale(), cfg.roundingMode())
+}
+
+// divScale implements decimal.div(x, y, scale): x / y with at most scale
+// fractional digits, rounded with the configured mode.
+func (cfg *decimalArithmeticConfig) divScale(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
+	d1, d2, err := cfg.parseOperands(operands)
+	if err != nil {
+		return err
+	}
+	scale, err := builtins.IntOperand(operands[2].Value, 3)
+	if err != nil {
+		return err
+	}
+	if scale < 0 || scale > cfg.maxScale() {
+		return fmt.Errorf("scale must be between 0 and %d, got %d", cfg.maxScale(), scale)
+	}
+	if d2.isZero() {
+		return errors.New("divide by zero")
+	}
+	result, err := cfg.roundRat(new(big.Rat).Quo(d1.rat(), d2.rat()), scale, cfg.roundingMode())
+	if err != nil {
+		return err
+	}
+	return numberResult(result, iter)
+}
+
 // === Comparison operations ===
 
-func precisionGT(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
-	d1, d2, err := parseOperands(operands)
+func (cfg *decimalArithmeticConfig) gt(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
+	d1, d2, err := cfg.parseOperands(operands)
 	if err != nil {
 		return err
 	}
-	return boolResult(d1.Cmp(d2) > 0, iter)
+	return boolResult(d1.cmp(d2) > 0, iter)
 }
 
-func precisionGTE(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
-	d1, d2, err := parseOperands(operands)
+func (cfg *decimalArithmeticConfig) gte(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
+	d1, d2, err := cfg.parseOperands(operands)
 	if err != nil {
 		return err
 	}
-	return boolResult(d1.Cmp(d2) >= 0, iter)
+	return boolResult(d1.cmp(d2) >= 0, iter)
 }
 
-func precisionLT(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
-	d1, d2, err := parseOperands(operands)
+func (cfg *decimalArithmeticConfig) lt(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
+	d1, d2, err := cfg.parseOperands(operands)
 	if err != nil {
 		return err
 	}
-	return boolResult(d1.Cmp(d2) < 0, iter)
+	return boolResult(d1.cmp(d2) < 0, iter)
 }
 
-func precisionLTE(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
-	d1, d2, err := parseOperands(operands)
+func (cfg *decimalArithmeticConfig) lte(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
+	d1, d2, err := cfg.parseOperands(operands)
 	if err != nil {
 		return err
 	}
-	return boolResult(d1.Cmp(d2) <= 0, iter)
+	return boolResult(d1.cmp(d2) <= 0, iter)
 }
 
-func precisionEqual(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
+func (cfg *decimalArithmeticConfig) equal(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
 	// equal is used for many types besides numbers, so use precise comparison only when both are numbers.
 	n1, ok1 := operands[0].Value.(ast.Number)
 	n2, ok2 := operands[1].Value.(ast.Number)
 
 	if ok1 && ok2 {
-		d1, err := parseDecimal(string(n1))
+		d1, err := cfg.parseNumber(string(n1))
 		if err != nil {
 			return err
 		}
-		d2, err := parseDecimal(string(n2))
+		d2, err := cfg.parseNumber(string(n2))
 		if err != nil {
 			return err
 		}
-		return boolResult(d1.Cmp(d2) == 0, iter)
+		return boolResult(d1.cmp(d2) == 0, iter)
 	}
 
 	// Default equality comparison for non-numbers.
 	return boolResult(operands[0].Value.Compare(operands[1].Value) == 0, iter)
 }
 
-func precisionNotEqual(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
+func (cfg *decimalArithmeticConfig) notEqual(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
 	n1, ok1 := operands[0].Value.(ast.Number)
 	n2, ok2 := operands[1].Value.(ast.Number)
 
 	if ok1 && ok2 {
-		d1, err := parseDecimal(string(n1))
+		d1, err := cfg.parseNumber(string(n1))
 		if err != nil {
 			return err
 		}
-		d2, err := parseDecimal(string(n2))
+		d2, err := cfg.parseNumber(string(n2))
 		if err != nil {
 			return err
 		}
-		return boolResult(d1.Cmp(d2) != 0, iter)
+		return boolResult(d1.cmp(d2) != 0, iter)
 	}
 
 	// Default comparison for non-numbers.
@@ -518,18 +811,18 @@ func precisionNotEqual(_ topdown.BuiltinContext, operands []*ast.Term, iter func
 
 // === Remainder operation ===
 
-func precisionRem(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
-	d1, d2, err := parseOperands(operands)
+func (cfg *decimalArithmeticConfig) rem(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
+	d1, d2, err := cfg.parseOperands(operands)
 	if err != nil {
 		return err
 	}
-	if d2.IsZero() {
+	if d2.isZero() {
 		// Return a plain error like standard OPA so it is handled with the
 		// eval_builtin_error code
 		// (OPA v1.11.0 topdown/arithmetic.go: errors.New("modulo by zero")).
 		return errors.New("modulo by zero")
 	}
-	result, err := d1.Mod(d2)
+	result, err := d1.mod(d2)
 	if err != nil {
 		return err
 	}
@@ -538,47 +831,61 @@ func precisionRem(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast
 
 // === Unary operations ===
 
-func parseUnaryOperand(operands []*ast.Term) (udecimal.Decimal, error) {
-	return operandToDecimal(operands[0].Value, 1)
+func (cfg *decimalArithmeticConfig) parseUnaryOperand(operands []*ast.Term) (number, error) {
+	return cfg.operandToDecimal(operands[0].Value, 1)
 }
 
-func precisionAbs(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
-	d, err := parseUnaryOperand(operands)
+func (cfg *decimalArithmeticConfig) abs(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
+	d, err := cfg.parseUnaryOperand(operands)
 	if err != nil {
 		return err
 	}
-	return numberResult(d.Abs(), iter)
+	return numberResult(d.abs(), iter)
 }
 
-func precisionRound(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
-	d, err := parseUnaryOperand(operands)
+func (cfg *decimalArithmeticConfig) round(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
+	d, err := cfg.parseUnaryOperand(operands)
+	if err != nil {
+		return err
+	}
+	mode := cfg.rounding
+	if mode == 0 {
+		// Round half away from zero (same as OPA's default behavior).
+		if !cfg.big {
+			return numberResult(number{d: d.d.RoundHAZ(0)}, iter)
+		}
+		mode = RoundHalfUp
+	}
+	result, err := cfg.roundRat(d.rat(), 0, mode)
 	if err != nil {
 		return err
 	}
-	// Round half away from zero (same as OPA's default behavior).
-	return numberResult(d.RoundHAZ(0), iter)
+	return numberResult(result, iter)
 }
 
-func precisionCeil(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
-	d, err := parseUnaryOperand(operands)
+func (cfg *decimalArithmeticConfig) ceil(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
+	d, err := cfg.parseUnaryOperand(operands)
 	if err != nil {
 		return err
 	}
-	return numberResult(d.Ceil(), iter)
+	return numberResult(d.ceil(), iter)
 }
 
-func precisionFloor(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
-	d, err := parseUnaryOperand(operands)
+func (cfg *decimalArithmeticConfig) floor(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
+	d, err := cfg.parseUnaryOperand(operands)
 	if err != nil {
 		return err
 	}
-	return numberResult(d.Floor(), iter)
+	return numberResult(d.floor(), iter)
 }
 
 // === Aggregate operations ===
 
-func precisionSum(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
-	sum := udecimal.Zero
+func (cfg *decimalArithmeticConfig) sum(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
+	sum := number{d: udecimal.Zero}
+	if cfg.big {
+		sum = number{r: new(big.Rat)}
+	}
 
 	switch a := operands[0].Value.(type) {
 	case *ast.Array:
@@ -587,12 +894,12 @@ func precisionSum(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast
 			if err != nil {
 				return
 			}
-			d, parseErr := elementToDecimal(a, x)
+			d, parseErr := cfg.elementToDecimal(a, x)
 			if parseErr != nil {
 				err = parseErr
 				return
 			}
-			sum = sum.Add(d)
+			sum = sum.add(d)
 		})
 		if err != nil {
 			return err
@@ -603,12 +910,12 @@ func precisionSum(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast
 			if err != nil {
 				return
 			}
-			d, parseErr := elementToDecimal(a, x)
+			d, parseErr := cfg.elementToDecimal(a, x)
 			if parseErr != nil {
 				err = parseErr
 				return
 			}
-			sum = sum.Add(d)
+			sum = sum.add(d)
 		})
 		if err != nil {
 			return err
@@ -620,8 +927,21 @@ func precisionSum(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast
 	return numberResult(sum, iter)
 }
 
-func precisionProduct(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
-	product := udecimal.One
+func (cfg *decimalArithmeticConfig) product(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
+	product := number{d: udecimal.One}
+	// With a rounding mode, a scale or BigDecimalBackend the product is
+	// computed exactly and rounded once.
+	var exact *big.Rat
+	if !cfg.truncates() {
+		exact = big.NewRat(1, 1)
+	}
+	mul := func(d number) {
+		if exact != nil {
+			exact.Mul(exact, d.rat())
+			return
+		}
+		product = number{d: product.d.Mul(d.d)}
+	}
 
 	switch a := operands[0].Value.(type) {
 	case *ast.Array:
@@ -630,12 +950,12 @@ func precisionProduct(_ topdown.BuiltinContext, operands []*ast.Term, iter func(
 			if err != nil {
 				return
 			}
-			d, parseErr := elementToDecimal(a, x)
+			d, parseErr := cfg.elementToDecimal(a, x)
 			if parseErr != nil {
 				err = parseErr
 				return
 			}
-			product = product.Mul(d)
+			mul(d)
 		})
 		if err != nil {
 			return err
@@ -646,12 +966,12 @@ func precisionProduct(_ topdown.BuiltinContext, operands []*ast.Term, iter func(
 			if err != nil {
 				return
 			}
-			d, parseErr := elementToDecimal(a, x)
+			d, parseErr := cfg.elementToDecimal(a, x)
 			if parseErr != nil {
 				err = parseErr
 				return
 			}
-			product = product.Mul(d)
+			mul(d)
 		})
 		if err != nil {
 			return err
@@ -660,6 +980,12 @@ func precisionProduct(_ topdown.BuiltinContext, operands []*ast.Term, iter func(
 		return builtins.NewOperandTypeErr(1, operands[0].Value, "set", "array")
 	}
 
+	if exact != nil {
+		var err error
+		if product, err = cfg.roundRat(exact, cfg.resultScale(), cfg.roundingMode()); err != nil {
+			return err
+		}
+	}
 	return numberResult(product, iter)
 }
 
@@ -673,23 +999,23 @@ func precisionProduct(_ topdown.BuiltinContext, operands []*ast.Term, iter func(
 // ast.String qualifies only when WithStringCoercion() is enabled and the string
 // parses as a number. This makes the fallback policy identical whether or not
 // coercion is enabled.
-func shouldUseNumericExtrema(foreach func(func(*ast.Term))) bool {
+func (cfg *decimalArithmeticConfig) shouldUseNumericExtrema(foreach func(func(*ast.Term))) bool {
 	allNumericLike := true
 	foreach(func(x *ast.Term) {
-		if !isNumericType(x.Value) {
+		if !cfg.isNumericType(x.Value) {
 			allNumericLike = false
 		}
 	})
 	return allNumericLike
 }
 
-func precisionMax(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
+func (cfg *decimalArithmeticConfig) max(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
 	switch a := operands[0].Value.(type) {
 	case *ast.Array:
 		if a.Len() == 0 {
 			return nil
 		}
-		useNumeric := shouldUseNumericExtrema(func(fn func(*ast.Term)) {
+		useNumeric := cfg.shouldUseNumericExtrema(func(fn func(*ast.Term)) {
 			a.Foreach(fn)
 		})
 		if !useNumeric {
@@ -703,7 +1029,7 @@ func precisionMax(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast
 			return iter(ast.NewTerm(max))
 		}
 		// Precise comparison when all elements are numeric.
-		var maxVal udecimal.Decimal
+		var maxVal number
 		var maxTerm *ast.Term
 		var numericErr error
 		first := true
@@ -711,12 +1037,12 @@ func precisionMax(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast
 			if numericErr != nil {
 				return
 			}
-			d, parseErr := elementToDecimal(a, x)
+			d, parseErr := cfg.elementToDecimal(a, x)
 			if parseErr != nil {
 				numericErr = parseErr
 				return
 			}
-			if first || d.Cmp(maxVal) > 0 {
+			if first || d.cmp(maxVal) > 0 {
 				maxVal = d
 				maxTerm = x
 				first = false
@@ -730,7 +1056,7 @@ func precisionMax(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast
 		if a.Len() == 0 {
 			return nil
 		}
-		useNumeric := shouldUseNumericExtrema(func(fn func(*ast.Term)) {
+		useNumeric := cfg.shouldUseNumericExtrema(func(fn func(*ast.Term)) {
 			a.Foreach(fn)
 		})
 		if !useNumeric {
@@ -744,7 +1070,7 @@ func precisionMax(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast
 			return iter(ast.NewTerm(max))
 		}
 		// Precise comparison when all elements are numeric.
-		var maxVal udecimal.Decimal
+		var maxVal number
 		var maxTerm *ast.Term
 		var numericErr error
 		first := true
@@ -752,12 +1078,12 @@ func precisionMax(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast
 			if numericErr != nil {
 				return
 			}
-			d, parseErr := elementToDecimal(a, x)
+			d, parseErr := cfg.elementToDecimal(a, x)
 			if parseErr != nil {
 				numericErr = parseErr
 				return
 			}
-			if first || d.Cmp(maxVal) > 0 {
+			if first || d.cmp(maxVal) > 0 {
 				maxVal = d
 				maxTerm = x
 				first = false
@@ -772,13 +1098,13 @@ func precisionMax(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast
 	}
 }
 
-func precisionMin(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
+func (cfg *decimalArithmeticConfig) min(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
 	switch a := operands[0].Value.(type) {
 	case *ast.Array:
 		if a.Len() == 0 {
 			return nil
 		}
-		useNumeric := shouldUseNumericExtrema(func(fn func(*ast.Term)) {
+		useNumeric := cfg.shouldUseNumericExtrema(func(fn func(*ast.Term)) {
 			a.Foreach(fn)
 		})
 		if !useNumeric {
@@ -792,7 +1118,7 @@ func precisionMin(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast
 			return iter(ast.NewTerm(min))
 		}
 		// Precise comparison when all elements are numeric.
-		var minVal udecimal.Decimal
+		var minVal number
 		var minTerm *ast.Term
 		var numericErr error
 		first := true
@@ -800,12 +1126,12 @@ func precisionMin(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast
 			if numericErr != nil {
 				return
 			}
-			d, parseErr := elementToDecimal(a, x)
+			d, parseErr := cfg.elementToDecimal(a, x)
 			if parseErr != nil {
 				numericErr = parseErr
 				return
 			}
-			if first || d.Cmp(minVal) < 0 {
+			if first || d.cmp(minVal) < 0 {
 				minVal = d
 				minTerm = x
 				first = false
@@ -819,7 +1145,7 @@ func precisionMin(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast
 		if a.Len() == 0 {
 			return nil
 		}
-		useNumeric := shouldUseNumericExtrema(func(fn func(*ast.Term)) {
+		useNumeric := cfg.shouldUseNumericExtrema(func(fn func(*ast.Term)) {
 			a.Foreach(fn)
 		})
 		if !useNumeric {
@@ -833,7 +1159,7 @@ func precisionMin(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast
 			return iter(ast.NewTerm(min))
 		}
 		// Precise comparison when all elements are numeric.
-		var minVal udecimal.Decimal
+		var minVal number
 		var minTerm *ast.Term
 		var numericErr error
 		first := true
@@ -841,12 +1167,12 @@ func precisionMin(_ topdown.BuiltinContext, operands []*ast.Term, iter func(*ast
 			if numericErr != nil {
 				return
 			}
-			d, parseErr := elementToDecimal(a, x)
+			d, parseErr := cfg.elementToDecimal(a, x)
 			if parseErr != nil {
 				numericErr = parseErr
 				return
 			}
-			if first || d.Cmp(minVal) < 0 {
+			if first || d.cmp(minVal) < 0 {
 				minVal = d
 				minTerm = x
 				first = false
@@ -0,0 +1,230 @@
+package regobrick
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/open-policy-agent/opa/v1/rego"
+	"github.com/open-policy-agent/opa/v1/topdown"
+)
+
+// callOperator applies the operator op under cfg and returns its result, or nil
+// if it returned none.
+func callOperator(cfg *decimalArithmeticConfig, op decimalOperatorFunc, operands ...*ast.Term) (ast.Value, error) {
+	var got ast.Value
+	err := op(cfg, topdown.BuiltinContext{}, operands, func(term *ast.Term) error {
+		got = term.Value
+		return nil
+	})
+	return got, err
+}
+
+// requireSameResult checks that both backends agree on a result: equal numbers
+// (compared as rationals, so formatting may differ), equal booleans, or the
+// same error status.
+func requireSameResult(t *testing.T, desc string, want, got ast.Value, wantErr, gotErr error) {
+	t.Helper()
+	if (wantErr != nil) != (gotErr != nil) {
+		t.Fatalf("%s: udecimal error %v, big error %v", desc, wantErr, gotErr)
+	}
+	if wantErr != nil {
+		return
+	}
+	wn, ok1 := want.(ast.Number)
+	gn, ok2 := got.(ast.Number)
+	if ok1 && ok2 {
+		if mustRat(t, string(wn)).Cmp(mustRat(t, string(gn))) != 0 {
+			t.Fatalf("%s: udecimal %s, big %s", desc, wn, gn)
+		}
+		return
+	}
+	if ast.Compare(want, got) != 0 {
+		t.Fatalf("%s: udecimal %v, big %v", desc, want, got)
+	}
+}
+
+// TestBigDecimalBackend_DifferentialVsUDecimal runs every operator and
+// aggregate on both backends. With a precision of 19 the big backend must agree
+// with udecimal wherever udecimal can represent the operands, including the
+// truncation of inexact products and quotients.
+func TestBigDecimalBackend_DifferentialVsUDecimal(t *testing.T) {
+	udec := newDecimalArithmeticConfig(nil)
+	bigCfg := newDecimalArithmeticConfig([]DecimalArithmeticOption{WithBackend(BigDecimalBackend{Precision: 19})})
+	rng := rand.New(rand.NewSource(20261016))
+
+	binary := []string{
+		ast.Plus.Name, ast.Minus.Name, ast.Multiply.Name, ast.Divide.Name, ast.Rem.Name,
+		ast.GreaterThan.Name, ast.GreaterThanEq.Name, ast.LessThan.Name, ast.LessThanEq.Name,
+		ast.Equal.Name, ast.NotEqual.Name,
+	}
+	unary := []string{ast.Abs.Name, ast.Round.Name, ast.Ceil.Name, ast.Floor.Name}
+	aggregates := []string{ast.Sum.Name, ast.Max.Name, ast.Min.Name}
+	if len(binary)+len(unary)+len(aggregates)+1 != len(decimalOperators) {
+		t.Fatalf("operator lists out of date: %d operators", len(decimalOperators))
+	}
+
+	gen := []func(*rand.Rand) string{randDecimalString, randHighFracDecimalString}
+	const iterations = 1000
+	for i := 0; i < iterations; i++ {
+		lhs := gen[i%2](rng)
+		rhs := gen[rng.Intn(2)](rng)
+		if i%50 == 0 {
+			rhs = "0"
+		}
+		a, b := ast.NumberTerm(json.Number(lhs)), ast.NumberTerm(json.Number(rhs))
+
+		for _, name := range binary {
+			op := decimalOperators[name]
+			want, wantErr := callOperator(&udec, op, a, b)
+			got, gotErr := callOperator(&bigCfg, op, a, b)
+			requireSameResult(t, fmt.Sprintf("%s(%s, %s)", name, lhs, rhs), want, got, wantErr, gotErr)
+		}
+		for _, name := range unary {
+			op := decimalOperators[name]
+			want, wantErr := callOperator(&udec, op, a)
+			got, gotErr := callOperator(&bigCfg, op, a)
+			requireSameResult(t, fmt.Sprintf("%s(%s)", name, lhs), want, got, wantErr, gotErr)
+		}
+
+		n := 1 + rng.Intn(20)
+		elems := make([]*ast.Term, n)
+		for j := range elems {
+			elems[j] = ast.NumberTerm(json.Number(gen[rng.Intn(2)](rng)))
+		}
+		for _, name := range aggregates {
+			op := decimalOperators[name]
+			arr := ast.ArrayTerm(elems...)
+			want, wantErr := callOperator(&udec, op, arr)
+			got, gotErr := callOperator(&bigCfg, op, arr)
+			requireSameResult(t, fmt.Sprintf("%s(%v)", name, arr), want, got, wantErr, gotErr)
+		}
+
+		// product: factors in udecimal's exact range, as it truncates each
+		// step while the big backend rounds the exact product once.
+		factors := make([]*ast.Term, 1+rng.Intn(5))
+		for j := range factors {
+			factors[j] = ast.NumberTerm(json.Number(fmt.Sprintf("%d.%03d", rng.Int63n(100)-50, rng.Int63n(1000))))
+		}
+		arr := ast.ArrayTerm(factors...)
+		want, wantErr := callOperator(&udec, decimalOperators[ast.Product.Name], arr)
+		got, gotErr := callOperator(&bigCfg, decimalOperators[ast.Product.Name], arr)
+		requireSameResult(t, fmt.Sprintf("product(%v)", arr), want, got, wantErr, gotErr)
+	}
+}
+
+func TestBigDecimalBackend_BeyondUDecimal(t *testing.T) {
+	ensureDecimalArithmeticEnabled()
+	t.Cleanup(func() {
+		UseDecimalArithmetic()
+	})
+	UseDecimalArithmetic(WithBackend(BigDecimalBackend{}), WithStringCoercion())
+
+	// 24-decimal token amounts, as strings from input.
+	input := map[string]any{
+		"a": "1.000000000000000000000001",
+		"b": "2.000000000000000000000002",
+	}
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"1e-25 + 1", "1.0000000000000000000000001"},
+		{"input.a + input.b", "3.000000000000000000000003"},
+		{"input.b - input.a", "1.000000000000000000000001"},
+		{"input.a * 3", "3.000000000000000000000003"},
+		{"sum([input.a, input.b])", "3.000000000000000000000003"},
+		{"1 / 3", "0." + strings.Repeat("3", 38)},
+		{"-2 / 3", "-0." + strings.Repeat("6", 38)},
+		{"round(input.a)", "1"},
+		{"ceil(input.a)", "2"},
+		{"decimal.div(1, 3, 24)", "0." + strings.Repeat("3", 24)},
+		{"max([input.a, input.b])", "2.000000000000000000000002"},
+	}
+	for _, tt := range tests {
+		rs := evalModuleResult(t, "package test\nimport rego.v1\nresult := "+tt.expr, input)
+		// max returns the element itself, a string under coercion.
+		if got := fmt.Sprint(requireSingleExprValue(t, rs)); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+
+	rs := evalModuleResult(t, "package test\nimport rego.v1\nresult := input.a > input.b", input)
+	if got := requireSingleExprValue(t, rs); got != false {
+		t.Errorf("comparison: got %v, want false", got)
+	}
+	rs = evalModuleResult(t, "package test\nimport rego.v1\nresult := 1e1001 + 1", nil)
+	requireUndefinedResult(t, rs)
+}
+
+func TestBigDecimalBackend_PrecisionAndRounding(t *testing.T) {
+	const module = `package test
+import data.regobrick.decimal_arithmetic
+
+result := {"div": 2 / 3, "mul": 0.00005 * 0.5, "product": product([0.00005, 0.5])}
+`
+	tests := []struct {
+		name string
+		opts []DecimalArithmeticOption
+		want string
+	}{
+		{"precision 4", nil, "map[div:0.6666 mul:0 product:0]"},
+		{"half even", []DecimalArithmeticOption{WithRoundingMode(RoundHalfEven)}, "map[div:0.6667 mul:0 product:0]"},
+		{"up", []DecimalArithmeticOption{WithRoundingMode(RoundUp)}, "map[div:0.6667 mul:0.0001 product:0.0001]"},
+		// The division scale applies to / only.
+		{"scale 2", []DecimalArithmeticOption{WithDivisionScale(2)}, "map[div:0.66 mul:0 product:0]"},
+	}
+	for _, tt := range tests {
+		opts := append([]DecimalArithmeticOption{WithBackend(BigDecimalBackend{Precision: 4})}, tt.opts...)
+		query, err := rego.New(
+			Module("test.rego", module, nil),
+			rego.Query("data.test.result"),
+			DecimalArithmetic(opts...),
+		).PrepareForEval(context.Background())
+		if err != nil {
+			t.Fatalf("%s: prepare error: %v", tt.name, err)
+		}
+		rs, err := query.Eval(context.Background())
+		if err != nil {
+			t.Fatalf("%s: eval error: %v", tt.name, err)
+		}
+		if got := fmt.Sprint(requireSingleExprValue(t, rs)); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWithBackend_InvalidPanics(t *testing.T) {
+	for _, backend := range []DecimalBackend{nil, BigDecimalBackend{Precision: -1}} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("expected a panic for %#v", backend)
+				}
+			}()
+			WithBackend(backend)
+		}()
+	}
+}
+
+func TestParseRatMatchesParseDecimal(t *testing.T) {
+	// Every string udecimal parses has the same value on the big backend.
+	bigCfg := newDecimalArithmeticConfig([]DecimalArithmeticOption{WithBackend(BigDecimalBackend{})})
+	for _, s := range []string{"0", "-0", "123.45", "1e-8", "-2.5E+3", "1.5e0", "0.0000000000000000001", "340282366920938463463374607431768211456"} {
+		d, err := parseDecimal(s)
+		if err != nil {
+			t.Fatalf("parseDecimal(%q): %v", s, err)
+		}
+		n, err := bigCfg.parseNumber(s)
+		if err != nil {
+			t.Fatalf("big parseNumber(%q): %v", s, err)
+		}
+		if n.rat().Cmp(mustRat(t, d.String())) != 0 {
+			t.Errorf("%q: udecimal %s, big %s", s, d, n)
+		}
+	}
+}
@@ -6,17 +6,17 @@ import (
 	"fmt"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/open-policy-agent/opa/v1/rego"
 )
 
-// Concurrency stress tests for the documented-safe usage pattern: configure
-// decimal arithmetic (and register builtins) once at startup, then evaluate
-// concurrently. Run under -race (as the CI test job does) these verify that
-// the evaluation read path — decimalConfig, the builtin registry, prepared
-// queries — is free of data races. Reconfiguring concurrently with evaluation
-// is documented as unsafe and is intentionally not exercised.
+// Concurrency stress tests for the documented-safe usage pattern: register the
+// decimal builtins once at startup, then evaluate concurrently, optionally
+// while reconfiguring. Run under -race (as the CI test job does) these verify
+// that the evaluation read path — decimalConfig, the builtin registry, prepared
+// queries — is free of data races.
 
 func init() {
 	RegisterBuiltin2[string, int, string]("stress_repeat", func(_ rego.BuiltinContext, s string, n int) (string, error) {
@@ -189,3 +189,80 @@ result := stress_repeat("x", %d)`, w+1)
 		t.Error(err)
 	}
 }
+
+func TestConcurrentEval_Reconfigure(t *testing.T) {
+	ensureDecimalArithmeticEnabled()
+	t.Cleanup(func() {
+		UseDecimalArithmetic()
+	})
+
+	// Both quotients come from the same evaluation, so they must agree on the
+	// configuration: each division scale truncates 1 / 3 to its own number of
+	// digits. Only the configuration is swapped here: RestoreStandardArithmetic
+	// writes OPA's registry and must not run concurrently with evaluations.
+	module := `package test
+import rego.v1
+result := {"x": input.a / input.b, "y": input.a / input.b}`
+	pq, err := rego.New(
+		rego.Query("data.test.result"),
+		rego.Module("test.rego", module),
+	).PrepareForEval(context.Background())
+	if err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+
+	var stop atomic.Bool
+	var toggler sync.WaitGroup
+	toggler.Add(1)
+	go func() {
+		defer toggler.Done()
+		for i := 0; !stop.Load(); i++ {
+			switch i % 3 {
+			case 0:
+				UseDecimalArithmetic(WithDivisionScale(2))
+			case 1:
+				UseDecimalArithmetic(WithDivisionScale(5), WithStringCoercion())
+			default:
+				UseDecimalArithmetic()
+			}
+			runtime.Gosched()
+		}
+	}()
+
+	workers := runtime.GOMAXPROCS(0) * 2
+	const evalsPerWorker = 100
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			ctx := context.Background()
+			input := map[string]any{"a": Number("1"), "b": Number("3")}
+			for i := 0; i < evalsPerWorker; i++ {
+				rs, err := pq.Eval(ctx, rego.EvalInput(input))
+				if err != nil {
+					errs <- fmt.Errorf("worker %d: eval: %w", w, err)
+					return
+				}
+				if len(rs) == 0 || len(rs[0].Expressions) == 0 {
+					errs <- fmt.Errorf("worker %d: undefined result", w)
+					return
+				}
+				m := rs[0].Expressions[0].Value.(map[string]any)
+				if m["x"] != m["y"] {
+					errs <- fmt.Errorf("worker %d: mixed configurations in one evaluation: %v", w, m)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	stop.Store(true)
+	toggler.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,166 @@
+package regobrick
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/open-policy-agent/opa/v1/ast"
+	"github.com/open-policy-agent/opa/v1/rego"
+	"github.com/sky1core/regobrick/internal/decimal"
+)
+
+func TestWithDivisionScale(t *testing.T) {
+	ensureDecimalArithmeticEnabled()
+	t.Cleanup(func() {
+		UseDecimalArithmetic()
+	})
+
+	module := `package test
+import rego.v1
+result := {
+	"div": 100 / 3,
+	"div_exact": 10 / 4,
+	"mul": 1.005 * 3,
+	"product": product([1.005, 3]),
+	"plus": 0.125 + 0.001,
+	"sum": sum([0.125, 0.001]),
+}`
+	tests := []struct {
+		name string
+		opts []DecimalArithmeticOption
+		want map[string]string
+	}{
+		{"truncated", []DecimalArithmeticOption{WithDivisionScale(2)}, map[string]string{
+			"div": "33.33", "div_exact": "2.5", "mul": "3.01", "product": "3.01", "plus": "0.126", "sum": "0.126",
+		}},
+		{"half_even", []DecimalArithmeticOption{WithDivisionScale(2), WithRoundingMode(RoundHalfEven)}, map[string]string{
+			"div": "33.33", "div_exact": "2.5", "mul": "3.02", "product": "3.02", "plus": "0.126", "sum": "0.126",
+		}},
+		{"integer", []DecimalArithmeticOption{WithDivisionScale(0), WithRoundingMode(RoundCeiling)}, map[string]string{
+			"div": "34", "div_exact": "3", "mul": "4", "product": "4", "plus": "0.126", "sum": "0.126",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			UseDecimalArithmetic(tt.opts...)
+			rs := evalModuleResult(t, module, nil)
+			for k, v := range requireSingleExprValue(t, rs).(map[string]any) {
+				if got := v.(json.Number).String(); got != tt.want[k] {
+					t.Errorf("%s: got %s, want %s", k, got, tt.want[k])
+				}
+			}
+		})
+	}
+}
+
+func TestWithDivisionScale_InvalidPanics(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		fn   func()
+	}{
+		{"negative", func() { WithDivisionScale(-1) }},
+		{"beyond udecimal", func() { DecimalArithmetic(WithDivisionScale(20)) }},
+		{"beyond big precision", func() {
+			DecimalArithmetic(WithBackend(BigDecimalBackend{Precision: 24}), WithDivisionScale(25))
+		}},
+	} {
+		func() {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("%s: expected a panic", tt.name)
+				}
+			}()
+			tt.fn()
+		}()
+	}
+	// The backend decides the bound, whatever the order of the options.
+	DecimalArithmetic(WithDivisionScale(24), WithBackend(BigDecimalBackend{Precision: 24}))
+}
+
+func TestDecimalDiv(t *testing.T) {
+	ensureDecimalArithmeticEnabled()
+	t.Cleanup(func() {
+		UseDecimalArithmetic()
+	})
+
+	tests := []struct {
+		expr string
+		want string // "" for undefined
+	}{
+		{"decimal.div(100, 3, 2)", "33.33"},
+		{"decimal.div(-2, 3, 4)", "-0.6666"},
+		{"decimal.div(2, 3, 0)", "0"},
+		{"decimal.div(10, 4, 19)", "2.5"},
+		{"decimal.div(1, 0, 2)", ""},
+		{"decimal.div(1, 3, 20)", ""},
+		{"decimal.div(1, 3, 1.5)", ""},
+	}
+	for _, tt := range tests {
+		module := "package test\nimport rego.v1\nresult := " + tt.expr
+		rs := evalModuleResult(t, module, nil)
+		if tt.want == "" {
+			requireUndefinedResult(t, rs)
+			continue
+		}
+		if got := requireSingleExprValue(t, rs).(json.Number).String(); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+
+	_, err := evalModule(t, "package test\nimport rego.v1\nresult := decimal.div(1, 3, 20)", nil, rego.StrictBuiltinErrors(true))
+	if err == nil || !strings.Contains(err.Error(), "decimal.div: scale must be between 0 and 19") {
+		t.Errorf("expected a scale error, got %v", err)
+	}
+
+	// The configured rounding mode applies; a restore unregisters decimal.div.
+	UseDecimalArithmetic(WithRoundingMode(RoundHalfUp))
+	rs := evalModuleResult(t, "package test\nimport rego.v1\nresult := decimal.div(2, 3, 2)", nil)
+	if got := requireSingleExprValue(t, rs).(json.Number).String(); got != "0.67" {
+		t.Errorf("half up: got %s, want 0.67", got)
+	}
+	RestoreStandardArithmetic()
+	_, err = rego.New(
+		rego.Query("data.test.result"),
+		rego.Module("test.rego", "package test\nimport rego.v1\nresult := decimal.div(2, 3, 2)"),
+	).PrepareForEval(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "undefined function decimal.div") {
+		t.Errorf("standard mode: expected an undefined function error, got %v", err)
+	}
+}
+
+func TestRestoreStandardArithmetic_CapabilitiesUnchanged(t *testing.T) {
+	ensureDecimalArithmeticEnabled()
+	t.Cleanup(func() {
+		UseDecimalArithmetic()
+	})
+
+	builtinNames := func() []string {
+		var names []string
+		for _, b := range ast.CapabilitiesForThisVersion().Builtins {
+			names = append(names, b.Name)
+		}
+		return names
+	}
+
+	RestoreStandardArithmetic()
+	standard := builtinNames()
+	if slices.Contains(standard, decimal.Div) {
+		t.Fatalf("standard mode: capabilities still declare %s", decimal.Div)
+	}
+	if _, ok := ast.BuiltinMap[decimal.Div]; ok {
+		t.Fatalf("standard mode: ast.BuiltinMap still declares %s", decimal.Div)
+	}
+
+	UseDecimalArithmetic()
+	if !slices.Contains(builtinNames(), decimal.Div) {
+		t.Fatalf("decimal mode: capabilities do not declare %s", decimal.Div)
+	}
+
+	RestoreStandardArithmetic()
+	if got := builtinNames(); !slices.Equal(got, standard) {
+		t.Errorf("capabilities changed by a Use/Restore cycle:\ngot  %v\nwant %v", got, standard)
+	}
+}
@@ -0,0 +1,152 @@
+package regobrick
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/open-policy-agent/opa/v1/rego"
+	"github.com/sky1core/regobrick/internal/decimal"
+)
+
+func TestDecimalOperators_CoverOperatorList(t *testing.T) {
+	if len(decimalOperators) != len(decimal.Operators) {
+		t.Errorf("decimalOperators has %d entries, decimal.Operators %d", len(decimalOperators), len(decimal.Operators))
+	}
+	for _, name := range decimal.Operators {
+		if decimalOperators[name] == nil {
+			t.Errorf("no decimal implementation for %q", name)
+		}
+	}
+}
+
+const scopedPolicy = `package pricing
+import data.regobrick.decimal_arithmetic
+
+total := input.price * input.qty + input.fee
+
+share := input.price / 3
+
+ratio := decimal.div(input.price, 3, 2)
+
+cheap if input.price < input.limit
+
+sum_fees := sum(input.fees)
+`
+
+func evalScoped(t *testing.T, query string, input map[string]any, opts ...func(*rego.Rego)) rego.ResultSet {
+	t.Helper()
+	ctx := context.Background()
+	args := append([]func(*rego.Rego){
+		Module("pricing.rego", scopedPolicy, nil),
+		rego.Query(query),
+	}, opts...)
+	pq, err := rego.New(args...).PrepareForEval(ctx)
+	if err != nil {
+		t.Fatalf("prepare error: %v", err)
+	}
+	rs, err := pq.Eval(ctx, rego.EvalInput(input))
+	if err != nil {
+		t.Fatalf("eval error: %v", err)
+	}
+	return rs
+}
+
+func TestDecimalArithmetic_QueryScoped(t *testing.T) {
+	input := map[string]any{
+		"price": json.Number("0.1"),
+		"qty":   json.Number("3"),
+		"fee":   json.Number("0.2"),
+		"fees":  []any{json.Number("0.1"), json.Number("0.2")},
+		"limit": json.Number("0.1000000000000000001"),
+	}
+	tests := []struct {
+		query string
+		want  any
+	}{
+		{"data.pricing.total", json.Number("0.5")},
+		{"data.pricing.share", json.Number("0.0333333333333333333")},
+		{"data.pricing.sum_fees", json.Number("0.3")},
+		{"data.pricing.cheap", true},
+	}
+	for _, tt := range tests {
+		rs := evalScoped(t, tt.query, input, DecimalArithmetic())
+		if got := requireSingleExprValue(t, rs); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.query, tt.want, got)
+		}
+	}
+}
+
+func TestDecimalArithmetic_PerQueryOptions(t *testing.T) {
+	input := map[string]any{"price": "0.1", "qty": "3", "fee": "0.2"}
+
+	rs := evalScoped(t, "data.pricing.total", input, DecimalArithmetic(WithStringCoercion()))
+	if got := requireSingleExprValue(t, rs); got != json.Number("0.5") {
+		t.Errorf("with coercion: expected 0.5, got %v", got)
+	}
+
+	// Another query in the same process keeps its own configuration.
+	rs = evalScoped(t, "data.pricing.total", input, DecimalArithmetic())
+	if len(rs) != 0 {
+		t.Errorf("without coercion: expected undefined, got %v", rs)
+	}
+}
+
+// TestDecimalArithmetic_LocalFunctionShadowsBuiltin checks that a function the
+// module defines under the name of an overloaded aggregate is called as is.
+func TestDecimalArithmetic_LocalFunctionShadowsBuiltin(t *testing.T) {
+	const policy = `package pricing
+import data.regobrick.decimal_arithmetic
+
+max(a, b) := a if a > b
+max(a, b) := b if a <= b
+
+bigger := max(input.a, input.b)
+`
+	rs, err := rego.New(
+		Module("pricing.rego", policy, nil),
+		rego.Query("data.pricing.bigger"),
+		rego.Input(map[string]any{"a": json.Number("0.1000000000000000001"), "b": json.Number("0.1")}),
+		DecimalArithmetic(),
+	).Eval(context.Background())
+	if err != nil {
+		t.Fatalf("eval error: %v", err)
+	}
+	if got := requireSingleExprValue(t, rs); got != json.Number("0.1000000000000000001") {
+		t.Errorf("expected the local max with decimal comparison, got %v", got)
+	}
+}
+
+func TestDecimalArithmetic_RewrittenModuleRequiresOption(t *testing.T) {
+	_, err := rego.New(
+		Module("pricing.rego", scopedPolicy, nil),
+		rego.Query("data.pricing.total"),
+	).PrepareForEval(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "regobrick.decimal.") {
+		t.Fatalf("expected an undefined function error for the scoped builtins, got: %v", err)
+	}
+}
+
+func TestDecimalArithmetic_DivisionScaleAndDiv(t *testing.T) {
+	input := map[string]any{"price": json.Number("2"), "qty": json.Number("3"), "fee": json.Number("0")}
+	tests := []struct {
+		query string
+		opts  []DecimalArithmeticOption
+		want  json.Number
+	}{
+		{"data.pricing.share", nil, "0.6666666666666666666"},
+		{"data.pricing.share", []DecimalArithmeticOption{WithDivisionScale(4)}, "0.6666"},
+		{"data.pricing.share", []DecimalArithmeticOption{WithDivisionScale(4), WithRoundingMode(RoundHalfUp)}, "0.6667"},
+		{"data.pricing.ratio", nil, "0.66"},
+		{"data.pricing.ratio", []DecimalArithmeticOption{WithRoundingMode(RoundHalfUp)}, "0.67"},
+		// The explicit scale wins over WithDivisionScale.
+		{"data.pricing.ratio", []DecimalArithmeticOption{WithDivisionScale(4)}, "0.66"},
+	}
+	for _, tt := range tests {
+		rs := evalScoped(t, tt.query, input, DecimalArithmetic(tt.opts...))
+		if got := requireSingleExprValue(t, rs); got != tt.want {
+			t.Errorf("%s with %d options: expected %v, got %v", tt.query, len(tt.opts), tt.want, got)
+		}
+	}
+}
@@ -9,12 +9,42 @@ import (
 	"sync"
 	"testing"
 
+	"github.com/open-policy-agent/opa/v1/ast"
 	"github.com/open-policy-agent/opa/v1/rego"
 	"github.com/open-policy-agent/opa/v1/topdown"
+	"github.com/open-policy-agent/opa/v1/topdown/builtins"
 )
 
 var operatorOnce sync.Once
 
+// The decimal operators bound to the process-global configuration, as
+// UseDecimalArithmetic registers them, for tests calling them directly.
+// Before UseDecimalArithmetic or after RestoreStandardArithmetic they use the
+// default configuration.
+var (
+	precisionPlus     = testDecimalOperator((*decimalArithmeticConfig).plus)
+	precisionMinus    = testDecimalOperator((*decimalArithmeticConfig).minus)
+	precisionMultiply = testDecimalOperator((*decimalArithmeticConfig).multiply)
+	precisionDivide   = testDecimalOperator((*decimalArithmeticConfig).divide)
+	precisionRem      = testDecimalOperator((*decimalArithmeticConfig).rem)
+	precisionGT       = testDecimalOperator((*decimalArithmeticConfig).gt)
+	precisionGTE      = testDecimalOperator((*decimalArithmeticConfig).gte)
+	precisionLT       = testDecimalOperator((*decimalArithmeticConfig).lt)
+	precisionLTE      = testDecimalOperator((*decimalArithmeticConfig).lte)
+	precisionEqual    = testDecimalOperator((*decimalArithmeticConfig).equal)
+	precisionNotEqual = testDecimalOperator((*decimalArithmeticConfig).notEqual)
+	precisionSum      = testDecimalOperator((*decimalArithmeticConfig).sum)
+	precisionProduct  = testDecimalOperator((*decimalArithmeticConfig).product)
+	precisionMax      = testDecimalOperator((*decimalArithmeticConfig).max)
+	precisionMin      = testDecimalOperator((*decimalArithmeticConfig).min)
+)
+
+func testDecimalOperator(op decimalOperatorFunc) topdown.BuiltinFunc {
+	return globalDecimalOperator(op, func(bctx topdown.BuiltinContext, operands []*ast.Term, iter func(*ast.Term) error) error {
+		return op(&decimalArithmeticConfig{}, bctx, operands, iter)
+	})
+}
+
 func ensureDecimalArithmeticEnabled() {
 	operatorOnce.Do(func() { UseDecimalArithmetic() })
 }
@@ -896,6 +926,73 @@ result := input.a + input.b`
 	requireUndefinedResult(t, rs)
 }
 
+func TestRestoreStandardArithmetic_PublicAPI(t *testing.T) {
+	ensureDecimalArithmeticEnabled()
+	t.Cleanup(func() {
+		UseDecimalArithmetic()
+	})
+
+	// String ordering tells the modes apart: decimal comparisons are numeric-only.
+	module := `package test
+import rego.v1
+result := input.a < input.b`
+	input := map[string]interface{}{"a": "a", "b": "b"}
+
+	rs := evalModuleResult(t, module, input)
+	requireUndefinedResult(t, rs)
+
+	RestoreStandardArithmetic()
+	rs = evalModuleResult(t, module, input)
+	if got := requireSingleExprValue(t, rs); got != true {
+		t.Fatalf("standard mode: got %v, want true", got)
+	}
+
+	UseDecimalArithmetic()
+	rs = evalModuleResult(t, module, input)
+	requireUndefinedResult(t, rs)
+}
+
+func TestRestoreStandardArithmetic_RegistryRestored(t *testing.T) {
+	ensureDecimalArithmeticEnabled()
+	t.Cleanup(func() {
+		UseDecimalArithmetic()
+	})
+
+	// An operator of regobrick's would use the configuration snapshot in the
+	// builtin cache and coerce the strings; OPA's own "+" rejects them.
+	plus := func() (ast.Value, error) {
+		cache := builtins.Cache{}
+		cache.Put(decimalConfigCacheKey{}, &decimalArithmeticConfig{stringCoercion: true})
+		var got ast.Value
+		err := topdown.GetBuiltin(ast.Plus.Name)(
+			topdown.BuiltinContext{Cache: cache},
+			[]*ast.Term{ast.StringTerm("1"), ast.StringTerm("2")},
+			func(term *ast.Term) error { got = term.Value; return nil },
+		)
+		return got, err
+	}
+
+	for i := 0; i < 3; i++ {
+		RestoreStandardArithmetic()
+		if got, err := plus(); err == nil {
+			t.Fatalf("cycle %d: standard mode: expected OPA's type error, got %v", i, got)
+		}
+		UseDecimalArithmetic()
+		if got, err := plus(); err != nil || got.Compare(ast.Number("3")) != 0 {
+			t.Fatalf("cycle %d: decimal mode: got %v, %v, want 3", i, got, err)
+		}
+	}
+}
+
+func TestWithRoundingMode_InvalidPanics(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("expected a panic for an undeclared rounding mode")
+		}
+	}()
+	WithRoundingMode(RoundingMode(0))
+}
+
 func enableStringCoercion(t *testing.T) {
 	t.Helper()
 	UseDecimalArithmetic(WithStringCoercion())
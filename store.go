@@ -0,0 +1,49 @@
+package regobrick
+
+import (
+	"context"
+	"io/fs"
+
+	"github.com/sky1core/regobrick/internal/module"
+)
+
+// PolicyStoreOptions is an alias for module.PolicyStoreOptions. It selects the
+// files a PolicyStore loads, how they are parsed (imports, features, Rego
+// version), and the named queries to prepare.
+type PolicyStoreOptions = module.PolicyStoreOptions
+
+// PolicyGeneration is an alias for module.PolicyGeneration: the immutable set of
+// queries prepared from one snapshot of the policy files.
+type PolicyGeneration = module.PolicyGeneration
+
+// PolicyStore is an alias for module.PolicyStore. It holds queries prepared from
+// the ".rego" files of an fs.FS and hot-reloads them: Reload (or Watch, which
+// polls) parses the changed files, prepares every query again and atomically
+// swaps the new generation in. A failed reload keeps the previous generation and
+// exposes the error through Err, so a bad policy push never takes evaluation down.
+type PolicyStore = module.PolicyStore
+
+// NewPolicyStore loads the ".rego" files of fsys (e.g. os.DirFS for a directory)
+// and prepares the queries of opts. Unlike Modules it never panics: the first
+// load returns every failing module as a *ModuleError, joined with errors.Join.
+//
+// Example:
+//
+//	store, err := regobrick.NewPolicyStore(ctx, os.DirFS("/etc/policies"), regobrick.PolicyStoreOptions{
+//	    Rules:   []regobrick.ImportRule{regobrick.ImportsForPackage("app.**", "data.lib.money")},
+//	    Queries: map[string]string{"allow": "data.app.allow"},
+//	    OnReload: func(gen *regobrick.PolicyGeneration, err error) {
+//	        if err != nil {
+//	            log.Printf("policy reload failed, serving generation %d: %v", gen.ID, err)
+//	        }
+//	    },
+//	})
+//	if err != nil {
+//	    return err
+//	}
+//	go store.Watch(ctx, 5*time.Second)
+//
+//	rs, err := store.Eval(ctx, "allow", rego.EvalInput(input))
+func NewPolicyStore(ctx context.Context, fsys fs.FS, opts PolicyStoreOptions) (*PolicyStore, error) {
+	return module.NewPolicyStore(ctx, fsys, opts)
+}
@@ -0,0 +1,40 @@
+package regobrick_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/sky1core/regobrick"
+)
+
+func TestPolicyStore_PublicAPI(t *testing.T) {
+	ctx := context.Background()
+	fsys := fstest.MapFS{
+		"app/policy.rego": {Data: []byte("package app\nimport data.regobrick.default_false\n\nallow if input.admin\n")},
+	}
+
+	store, err := regobrick.NewPolicyStore(ctx, fsys, regobrick.PolicyStoreOptions{
+		Queries: map[string]string{"allow": "data.app.allow"},
+	})
+	if err != nil {
+		t.Fatalf("NewPolicyStore failed: %v", err)
+	}
+	rs, err := store.Eval(ctx, "allow")
+	if err != nil || len(rs) != 1 || rs[0].Expressions[0].Value != false {
+		t.Fatalf("expected default false, got %v (err %v)", rs, err)
+	}
+
+	fsys["app/policy.rego"] = &fstest.MapFile{Data: []byte("package app\nimport data.regobrick.default_false\n\nallow if {\n")}
+	if _, err := store.Reload(ctx); err == nil {
+		t.Fatal("expected reload of a broken policy to fail")
+	}
+	var modErr *regobrick.ModuleError
+	if !errors.As(store.Err(), &modErr) || modErr.Filename != "app/policy.rego" {
+		t.Errorf("expected *ModuleError from Err, got %v", store.Err())
+	}
+	if rs, err := store.Eval(ctx, "allow"); err != nil || len(rs) != 1 {
+		t.Errorf("expected the previous generation to keep serving, got %v (err %v)", rs, err)
+	}
+}